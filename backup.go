@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the timestamp suffix of backup files.
+const backupTimeFormat = "20060102-150405"
+
+// backupFile copies the file at path to <name>.bak-<timestamp> and returns
+// the backup's path. The copy is written to dir when set (creating it if
+// needed), and next to the original otherwise.
+func backupFile(path, dir string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := filepath.Base(path) + ".bak-" + time.Now().Format(backupTimeFormat)
+	dst := filepath.Join(dir, name)
+	if err := os.WriteFile(dst, b, 0o644); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// defaultBackupKeep is how many backups of each file are kept when the
+// config doesn't say.
+const defaultBackupKeep = 5
+
+// pruneBackups removes all but the newest keep backups of the file at path
+// from dir and returns the removed paths. The timestamp suffix sorts
+// chronologically, so names are compared rather than mod times. keep <= 0
+// keeps everything.
+func pruneBackups(path, dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(path) + ".bak-"
+	var backups []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) {
+			backups = append(backups, e.Name())
+		}
+	}
+	if len(backups) <= keep {
+		return nil, nil
+	}
+	sort.Strings(backups)
+	var removed []string
+	for _, name := range backups[:len(backups)-keep] {
+		p := filepath.Join(dir, name)
+		if err := os.Remove(p); err != nil {
+			return removed, err
+		}
+		removed = append(removed, p)
+	}
+	return removed, nil
+}
+
+// backupIgnorePattern is the .gitignore entry that matches backup files.
+const backupIgnorePattern = "*.bak-*"
+
+// ensureBackupGitignore makes sure the .gitignore in dir ignores backup
+// files, creating the file or appending to it. A pattern that is already
+// listed is left alone.
+func ensureBackupGitignore(dir string) error {
+	p := filepath.Join(dir, ".gitignore")
+	b, err := os.ReadFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(line) == backupIgnorePattern {
+			return nil
+		}
+	}
+	entry := backupIgnorePattern + "\n"
+	if len(b) > 0 && !strings.HasSuffix(string(b), "\n") {
+		entry = "\n" + entry
+	}
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
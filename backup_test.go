@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupFile_AlongsideByDefault(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	dst, err := backupFile(p, "")
+	if err != nil {
+		t.Fatalf("backupFile error: %v", err)
+	}
+	if filepath.Dir(dst) != filepath.Dir(p) {
+		t.Errorf("backup should sit next to the file, got %s", dst)
+	}
+	if !strings.HasPrefix(filepath.Base(dst), "cliesp.yml.bak-") {
+		t.Errorf("unexpected backup name %s", dst)
+	}
+}
+
+func TestBackupFile_CentralDir(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	dir := filepath.Join(t.TempDir(), "backups", "nested")
+	dst, err := backupFile(p, dir)
+	if err != nil {
+		t.Fatalf("backupFile error: %v", err)
+	}
+	if filepath.Dir(dst) != dir {
+		t.Errorf("backup should be in %s, got %s", dir, dst)
+	}
+	b, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != matchFileHeader {
+		t.Errorf("backup content mismatch: %q", string(b))
+	}
+	entries, err := os.ReadDir(filepath.Dir(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("nothing should be written next to the file, found %d entries", len(entries))
+	}
+}
+
+func TestEnsureBackupGitignore_Creates(t *testing.T) {
+	dir := t.TempDir()
+	if err := ensureBackupGitignore(dir); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "*.bak-*\n" {
+		t.Errorf("unexpected .gitignore %q", string(b))
+	}
+}
+
+func TestEnsureBackupGitignore_AppendsOnce(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, ".gitignore")
+	if err := os.WriteFile(p, []byte("*.swp"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := ensureBackupGitignore(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "*.swp\n*.bak-*\n" {
+		t.Errorf("unexpected .gitignore %q", string(b))
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "cliesp.yml")
+	names := []string{
+		"cliesp.yml.bak-20240101-120000",
+		"cliesp.yml.bak-20240102-120000",
+		"cliesp.yml.bak-20240103-120000",
+		"cliesp.yml.bak-20240104-120000",
+		"other.yml.bak-20230101-120000",
+	}
+	for _, n := range append(names, "cliesp.yml") {
+		if err := os.WriteFile(filepath.Join(dir, n), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := pruneBackups(p, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || filepath.Base(removed[0]) != names[0] || filepath.Base(removed[1]) != names[1] {
+		t.Errorf("expected the two oldest to be removed, got %v", removed)
+	}
+	for _, n := range names[2:] {
+		if _, err := os.Stat(filepath.Join(dir, n)); err != nil {
+			t.Errorf("%s should be kept: %v", n, err)
+		}
+	}
+
+	if removed, err := pruneBackups(p, "", 0); err != nil || len(removed) != 0 {
+		t.Errorf("keep=0 should remove nothing, got %v, %v", removed, err)
+	}
+}
+
+func TestRun_BackupSkipsEmptyFile(t *testing.T) {
+	p := writeMatchFixture(t, "")
+	code, stdout, stderr := runCLI(t, ":a\nA\n\n", "--matchFile", p, "--backup")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if strings.Contains(stdout, "Backed up") {
+		t.Errorf("an empty file should not be backed up, got %q", stdout)
+	}
+}
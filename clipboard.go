@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// errNoClipboardTool is returned by readClipboard when none of the
+// platform's clipboard commands is installed.
+var errNoClipboardTool = errors.New("no clipboard tool found")
+
+// clipboardCommands lists the commands that print the clipboard on this
+// platform, most suitable first. On Linux, wl-paste comes first under
+// Wayland and last under X11.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbpaste"}}
+	case "windows":
+		return [][]string{{"powershell", "-NoProfile", "-Command", "Get-Clipboard"}}
+	default:
+		x11 := [][]string{{"xclip", "-selection", "clipboard", "-o"}, {"xsel", "--clipboard", "--output"}}
+		wayland := []string{"wl-paste", "--no-newline"}
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			return append([][]string{wayland}, x11...)
+		}
+		return append(x11, wayland)
+	}
+}
+
+// readClipboard returns the text on the system clipboard using the first
+// available command from clipboardCommands. Only the command's stdout is
+// used, so tool warnings can't leak into the text. Windows line endings and
+// a single trailing newline are dropped, as with other replacement sources.
+func readClipboard() (string, error) {
+	for _, c := range clipboardCommands() {
+		if _, err := lookPath(c[0]); err != nil {
+			continue
+		}
+		out, err := runCommand(nil, c[0], c[1:]...)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", c[0], err)
+		}
+		s := strings.ReplaceAll(string(out), "\r\n", "\n")
+		return strings.TrimSuffix(s, "\n"), nil
+	}
+	return "", errNoClipboardTool
+}
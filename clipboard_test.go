@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// noClipboardTools makes every lookPath call fail, as on a machine without
+// any clipboard command installed.
+func noClipboardTools(t *testing.T) {
+	t.Helper()
+	orig := lookPath
+	lookPath = func(name string) (string, error) { return "", exec.ErrNotFound }
+	t.Cleanup(func() { lookPath = orig })
+}
+
+func TestReadClipboard(t *testing.T) {
+	calls := fakeRunner(t, "line one\r\nline two\r\n", nil)
+	got, err := readClipboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "line one\nline two" {
+		t.Errorf("got %q", got)
+	}
+	if want := strings.Join(clipboardCommands()[0], " "); len(*calls) != 1 || (*calls)[0] != want {
+		t.Errorf("calls = %q, want [%q]", *calls, want)
+	}
+}
+
+func TestReadClipboard_IgnoresToolWarnings(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake clipboard tool needs a POSIX shell")
+	}
+	t.Setenv("WAYLAND_DISPLAY", "")
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'Warning: failed to set locale' >&2\nprintf 'copied\\n'\n"
+	if err := os.WriteFile(filepath.Join(dir, clipboardCommands()[0][0]), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	got, err := readClipboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "copied" {
+		t.Errorf("got %q, want the clipboard text only", got)
+	}
+}
+
+func TestReadClipboard_NoTool(t *testing.T) {
+	noClipboardTools(t)
+	if _, err := readClipboard(); !errors.Is(err, errNoClipboardTool) {
+		t.Errorf("err = %v, want errNoClipboardTool", err)
+	}
+}
+
+func TestRun_Clipboard(t *testing.T) {
+	fakeRunner(t, "Copied text\n", nil)
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "-t", ":cb", "-c")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	matches, err := parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Replace != "Copied text" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+func TestRun_ClipboardFallsBackToPrompt(t *testing.T) {
+	noClipboardTools(t)
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, ":cb\nTyped\n\n", "--matchFile", p, "--clipboard")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stderr, "no clipboard tool found") {
+		t.Errorf("expected a warning, got %q", stderr)
+	}
+	matches, err := parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Replace != "Typed" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
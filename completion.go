@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// completionProfiles is the --completion argument the generated scripts use
+// to ask cliesp for the profile names in the current config, so completions
+// follow config changes without regenerating the script.
+const completionProfiles = "profiles"
+
+// completionFlag is a flag as the completion scripts see it.
+type completionFlag struct {
+	name  string
+	usage string
+	// takesValue is false for boolean flags.
+	takesValue bool
+}
+
+// completionFlags lists every flag cliesp accepts, in lexical order.
+func completionFlags() []completionFlag {
+	var flags []completionFlag
+	fs := flag.NewFlagSet("cliesp", flag.ContinueOnError)
+	defineFlags(fs, &cliFlags{})
+	fs.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{name: f.Name, usage: f.Usage, takesValue: !ok || !b.IsBoolFlag()})
+	})
+	return flags
+}
+
+// dashed returns the flag as typed on the command line: -x for single
+// letters, --name otherwise.
+func (f completionFlag) dashed() string {
+	if len(f.name) == 1 {
+		return "-" + f.name
+	}
+	return "--" + f.name
+}
+
+// generateCompletion returns a completion script for shell: "bash", "zsh"
+// or "fish". The scripts complete flag names, files after --matchFile and
+// profile names after --profile.
+func generateCompletion(shell string) (string, error) {
+	flags := completionFlags()
+	switch strings.ToLower(shell) {
+	case "bash":
+		return bashCompletion(flags), nil
+	case "zsh":
+		return zshCompletion(flags), nil
+	case "fish":
+		return fishCompletion(flags), nil
+	default:
+		return "", fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", shell)
+	}
+}
+
+func bashCompletion(flags []completionFlag) string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = f.dashed()
+	}
+	var b strings.Builder
+	b.WriteString("# bash completion for cliesp\n")
+	b.WriteString("# Load it with: source <(cliesp --completion bash)\n")
+	b.WriteString("_cliesp() {\n")
+	b.WriteString("\tlocal cur prev\n")
+	b.WriteString("\tcur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("\tprev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("\tcase \"$prev\" in\n")
+	b.WriteString("\t--profile|-P)\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$(cliesp --completion " + completionProfiles + " 2>/dev/null)\" -- \"$cur\"))\n")
+	b.WriteString("\t\treturn\n\t\t;;\n")
+	b.WriteString("\t--matchFile|-m)\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	b.WriteString("\t\treturn\n\t\t;;\n")
+	b.WriteString("\tesac\n")
+	b.WriteString("\tCOMPREPLY=($(compgen -W \"" + strings.Join(names, " ") + "\" -- \"$cur\"))\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -F _cliesp cliesp\n")
+	return b.String()
+}
+
+func zshCompletion(flags []completionFlag) string {
+	var b strings.Builder
+	b.WriteString("#compdef cliesp\n")
+	b.WriteString("# Load it with: source <(cliesp --completion zsh)\n")
+	b.WriteString("_cliesp() {\n")
+	b.WriteString("\tlocal -a flags\n")
+	b.WriteString("\tflags=(\n")
+	for _, f := range flags {
+		b.WriteString("\t\t" + zshQuote(f.dashed()+":"+f.usage) + "\n")
+	}
+	b.WriteString("\t)\n")
+	b.WriteString("\tcase \"${words[CURRENT-1]}\" in\n")
+	b.WriteString("\t--profile|-P)\n")
+	b.WriteString("\t\tcompadd -- ${(f)\"$(cliesp --completion " + completionProfiles + " 2>/dev/null)\"}\n")
+	b.WriteString("\t\treturn\n\t\t;;\n")
+	b.WriteString("\t--matchFile|-m)\n")
+	b.WriteString("\t\t_files\n")
+	b.WriteString("\t\treturn\n\t\t;;\n")
+	b.WriteString("\tesac\n")
+	b.WriteString("\t_describe 'flag' flags\n")
+	b.WriteString("}\n")
+	b.WriteString("compdef _cliesp cliesp\n")
+	return b.String()
+}
+
+// zshQuote single-quotes s for zsh. Colons other than the first separate
+// _describe fields, so they're escaped.
+func zshQuote(s string) string {
+	name, desc, _ := strings.Cut(s, ":")
+	s = name + ":" + strings.ReplaceAll(desc, ":", `\:`)
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func fishCompletion(flags []completionFlag) string {
+	var b strings.Builder
+	b.WriteString("# fish completion for cliesp\n")
+	b.WriteString("# Load it with: cliesp --completion fish | source\n")
+	for _, f := range flags {
+		opt := "-l " + f.name
+		if len(f.name) == 1 {
+			opt = "-s " + f.name
+		}
+		line := "complete -c cliesp " + opt
+		switch {
+		case f.name == "profile" || f.name == "P":
+			line += " -x -a '(cliesp --completion " + completionProfiles + " 2>/dev/null)'"
+		case f.name == "matchFile" || f.name == "m":
+			line += " -r -F"
+		case f.takesValue:
+			line += " -x"
+		}
+		b.WriteString(line + " -d " + fishQuote(f.usage) + "\n")
+	}
+	return b.String()
+}
+
+// fishQuote single-quotes s for fish, where only \ and ' need escaping.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateCompletion(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{"complete -F _cliesp cliesp", "--matchFile", " -m ", "--openDir", "--completion profiles"}},
+		{"zsh", []string{"#compdef cliesp", "compdef _cliesp cliesp", "'--matchFile:", "'--open:", "--completion profiles"}},
+		{"fish", []string{"complete -c cliesp -l matchFile -r -F", "complete -c cliesp -s m -r -F", "complete -c cliesp -l openDir -d", "-l profile -x -a '(cliesp --completion profiles 2>/dev/null)'"}},
+	}
+	for _, tt := range tests {
+		script, err := generateCompletion(tt.shell)
+		if err != nil {
+			t.Fatalf("generateCompletion(%q) error: %v", tt.shell, err)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(script, w) {
+				t.Errorf("%s script lacks %q", tt.shell, w)
+			}
+		}
+	}
+
+	if _, err := generateCompletion("powershell"); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
+
+func TestFishQuote(t *testing.T) {
+	if got, want := fishQuote(`espanso's \n`), `'espanso\'s \\n'`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRun_Completion(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "--completion", "zsh")
+	if code != 0 || !strings.HasPrefix(stdout, "#compdef cliesp\n") {
+		t.Fatalf("exit=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+
+	code, stdout, _ = runCLI(t, "", "--completion", "profiles")
+	if code != 0 || stdout != "" {
+		t.Errorf("profiles without config: exit=%d stdout=%q", code, stdout)
+	}
+
+	if code, _, _ := runCLI(t, "", "--completion", "tcsh"); code != exitUsage {
+		t.Errorf("unsupported shell: exit=%d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configEnvVar selects an environment-specific settings file, e.g.
+// CLIESP_ENV=work layers settings.work.yaml over settings.yaml.
+const configEnvVar = "CLIESP_ENV"
+
+// configDir returns the directory holding cliesp's settings files:
+// $XDG_CONFIG_HOME/cliesp when XDG_CONFIG_HOME is set, ~/.config/cliesp
+// otherwise.
+func configDir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "cliesp"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "cliesp"), nil
+}
+
+// applyEnvConfig layers settings.<env>.yaml (or .yml) from dir over cfg. Keys
+// missing from that file keep their current value, and environment variables
+// still take precedence over it. A missing file is not an error.
+func applyEnvConfig(cfg *AppConfig, dir, env string) error {
+	if env == "" {
+		return nil
+	}
+	if strings.ContainsAny(env, `/\`) {
+		return fmt.Errorf("invalid %s %q", configEnvVar, env)
+	}
+	for _, ext := range []string{"yaml", "yml"} {
+		b, err := os.ReadFile(filepath.Join(dir, "settings."+env+"."+ext))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(b, cfg); err != nil {
+			return fmt.Errorf("settings.%s.%s: %w", env, ext, err)
+		}
+		return applyEnvOverrides(cfg)
+	}
+	return nil
+}
+
+// applyEnvOverrides sets fields from their CLIESP_* environment variables,
+// re-asserting their precedence after a settings file was layered on top.
+func applyEnvOverrides(cfg *AppConfig) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		val, ok := os.LookupEnv(envPrefix + tag)
+		if !ok {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(val)
+		case reflect.Int:
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", envPrefix, tag, err)
+			}
+			f.SetInt(int64(n))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", envPrefix, tag, err)
+			}
+			f.SetBool(b)
+		}
+	}
+	return nil
+}
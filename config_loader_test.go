@@ -35,6 +35,29 @@ func TestConfigLoader_FromFile(t *testing.T) {
 	}
 }
 
+func TestConfigLoader_FromTOMLFile(t *testing.T) {
+	tdir := t.TempDir()
+	cfgPath := filepath.Join(tdir, "settings.toml")
+	toml := []byte("match_dir = \"/tmp/fromtoml\"\nmatch_file = \"toml.yml\"\n")
+	if err := os.WriteFile(cfgPath, toml, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ldr := cfgpkg.NewLoader(cfgpkg.Options[AppConfig]{
+		AppName:        "cliesp",
+		ConsumerConfig: AppConfig{MatchDir: "", MatchFile: ""},
+	})
+	ldr.SetConfigPath(tdir)
+
+	cfg, err := ldr.Load()
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if cfg.MatchDir != "/tmp/fromtoml" || cfg.MatchFile != "toml.yml" {
+		t.Fatalf("unexpected config from toml file: %+v", cfg)
+	}
+}
+
 func TestConfigLoader_FromEnvFile(t *testing.T) {
 	tdir := t.TempDir()
 	envPath := filepath.Join(tdir, ".env")
@@ -69,11 +92,7 @@ func TestConfigLoader_EnvOverridesFile(t *testing.T) {
 	}
 
 	// Set process env to override dir only
-	old := os.Getenv("CLIESP_MATCH_DIR")
-	defer os.Setenv("CLIESP_MATCH_DIR", old)
-	if err := os.Setenv("CLIESP_MATCH_DIR", "/tmp/fromenvvar"); err != nil {
-		t.Fatal(err)
-	}
+	t.Setenv("CLIESP_MATCH_DIR", "/tmp/fromenvvar")
 
 	ldr := cfgpkg.NewLoader(cfgpkg.Options[AppConfig]{
 		AppName:        "cliesp",
@@ -93,6 +112,34 @@ func TestConfigLoader_EnvOverridesFile(t *testing.T) {
 	}
 }
 
+func TestConfigLoader_EnvOverridesTOMLFile(t *testing.T) {
+	tdir := t.TempDir()
+	cfgPath := filepath.Join(tdir, "settings.toml")
+	toml := []byte("match_dir = \"/tmp/fromtoml\"\nmatch_file = \"toml.yml\"\n")
+	if err := os.WriteFile(cfgPath, toml, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CLIESP_MATCH_DIR", "/tmp/fromenvvar")
+
+	ldr := cfgpkg.NewLoader(cfgpkg.Options[AppConfig]{
+		AppName:        "cliesp",
+		ConsumerConfig: AppConfig{MatchDir: "", MatchFile: ""},
+	})
+	ldr.SetConfigPath(tdir)
+
+	cfg, err := ldr.Load()
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if cfg.MatchDir != "/tmp/fromenvvar" {
+		t.Fatalf("env var should override toml for MatchDir: %+v", cfg)
+	}
+	if cfg.MatchFile != "toml.yml" {
+		t.Fatalf("MatchFile should remain from toml: %+v", cfg)
+	}
+}
+
 func TestResolveMatchPath_PrecendenceFlagOverConfig(t *testing.T) {
 	tdir := t.TempDir()
 	cfg := AppConfig{MatchDir: tdir, MatchFile: "file.yml"}
@@ -105,3 +152,99 @@ func TestResolveMatchPath_PrecendenceFlagOverConfig(t *testing.T) {
 		t.Fatalf("flag path should win: got=%q want=%q", p, flagPath)
 	}
 }
+
+func TestApplyEnvConfig_OverridesBase(t *testing.T) {
+	tdir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tdir, "settings.yaml"), []byte("match_dir: /tmp/base\nmatch_file: base.yml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tdir, "settings.work.yaml"), []byte("match_file: work.yml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ldr := cfgpkg.NewLoader(cfgpkg.Options[AppConfig]{AppName: "cliesp"})
+	ldr.SetConfigPath(tdir)
+	cfg, err := ldr.Load()
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if err := applyEnvConfig(&cfg, tdir, "work"); err != nil {
+		t.Fatalf("applyEnvConfig error: %v", err)
+	}
+	if cfg.MatchFile != "work.yml" {
+		t.Errorf("MatchFile = %q, want the env-specific work.yml", cfg.MatchFile)
+	}
+	if cfg.MatchDir != "/tmp/base" {
+		t.Errorf("MatchDir = %q, want the base value kept", cfg.MatchDir)
+	}
+}
+
+func TestApplyEnvConfig_EnvVarsStillWin(t *testing.T) {
+	tdir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tdir, "settings.work.yml"), []byte("match_file: work.yml\nmax_trigger_length: 5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLIESP_MATCH_FILE", "fromenv.yml")
+
+	cfg := AppConfig{MatchFile: "fromenv.yml"}
+	if err := applyEnvConfig(&cfg, tdir, "work"); err != nil {
+		t.Fatalf("applyEnvConfig error: %v", err)
+	}
+	if cfg.MatchFile != "fromenv.yml" || cfg.MaxTriggerLength != 5 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestApplyEnvConfig_MissingFileIgnored(t *testing.T) {
+	cfg := AppConfig{MatchFile: "base.yml"}
+	if err := applyEnvConfig(&cfg, t.TempDir(), "prod"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MatchFile != "base.yml" {
+		t.Errorf("config changed without an env file: %+v", cfg)
+	}
+	if err := applyEnvConfig(&cfg, t.TempDir(), "../prod"); err == nil {
+		t.Error("expected an error for an env name with a path separator")
+	}
+}
+
+func TestConfigDir_XDGConfigHome(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	if err := os.MkdirAll(filepath.Join(xdg, "cliesp"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(xdg, "cliesp", "settings.yaml"), []byte("match_dir: /tmp/fromxdg\nmatch_file: xdg.yml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		t.Fatalf("configDir error: %v", err)
+	}
+	if want := filepath.Join(xdg, "cliesp"); dir != want {
+		t.Fatalf("configDir() = %q, want %q", dir, want)
+	}
+	ldr := cfgpkg.NewLoader(cfgpkg.Options[AppConfig]{AppName: "cliesp"})
+	ldr.SetConfigPath(dir)
+	cfg, err := ldr.Load()
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if cfg.MatchDir != "/tmp/fromxdg" || cfg.MatchFile != "xdg.yml" {
+		t.Fatalf("unexpected config from XDG_CONFIG_HOME: %+v", cfg)
+	}
+}
+
+func TestConfigDir_FallsBackToHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	dir, err := configDir()
+	if err != nil {
+		t.Fatalf("configDir error: %v", err)
+	}
+	if want := filepath.Join(home, ".config", "cliesp"); dir != want {
+		t.Errorf("configDir() = %q, want %q", dir, want)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -56,3 +57,80 @@ func TestResolveMatchPath_ConfigDirAndFile(t *testing.T) {
 		t.Errorf("got %q want %q", p, filepath.Join(tdir, "abc.yml"))
 	}
 }
+
+func TestRouteByPrefix(t *testing.T) {
+	tdir := t.TempDir()
+	cfg := AppConfig{MatchDir: tdir, MatchFile: "cliesp.yml"}
+	routes := map[string]string{
+		":work-":     "work.yml",
+		":work-mtg-": "meetings.yml",
+		":dev-":      filepath.Join(tdir, "sub", "dev.yml"),
+	}
+
+	tests := []struct {
+		trigger string
+		want    string
+		ok      bool
+	}{
+		{trigger: ":work-standup", want: filepath.Join(tdir, "work.yml"), ok: true},
+		{trigger: ":work-mtg-notes", want: filepath.Join(tdir, "meetings.yml"), ok: true},
+		{trigger: ":dev-log", want: filepath.Join(tdir, "sub", "dev.yml"), ok: true},
+		{trigger: ":home", want: "", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := routeByPrefix(tt.trigger, routes, cfg)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("routeByPrefix(%q) = %q, %v; want %q, %v", tt.trigger, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRouteByPrefix_NoRoutes(t *testing.T) {
+	if _, ok := routeByPrefix(":x", nil, AppConfig{}); ok {
+		t.Fatal("expected no route with an empty routes map")
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	tdir := t.TempDir()
+	cfg := AppConfig{
+		MatchDir:  tdir,
+		MatchFile: "cliesp.yml",
+		Profiles: map[string]string{
+			"work":     "work.yml",
+			"personal": filepath.Join(tdir, "home", "personal.yml"),
+		},
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "work", want: filepath.Join(tdir, "work.yml")},
+		{name: "personal", want: filepath.Join(tdir, "home", "personal.yml")},
+	}
+	for _, tt := range tests {
+		got, err := resolveProfile(tt.name, cfg)
+		if err != nil {
+			t.Fatalf("resolveProfile(%q) error: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("resolveProfile(%q) = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveProfile_Unknown(t *testing.T) {
+	cfg := AppConfig{Profiles: map[string]string{"work": "work.yml", "home": "home.yml"}}
+	_, err := resolveProfile("play", cfg)
+	if err == nil {
+		t.Fatal("expected an error for an unknown profile")
+	}
+	if want := `unknown profile "play" (known profiles: home, work)`; err.Error() != want {
+		t.Errorf("got %q want %q", err, want)
+	}
+
+	if _, err := resolveProfile("work", AppConfig{}); err == nil || !strings.Contains(err.Error(), "no profiles configured") {
+		t.Errorf("unexpected error with no profiles: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// entryStart returns the 0-based index of the first line belonging to the
+// entry whose `- ` item is on 1-based line n: comments directly above it
+// (such as a cliesp-id) and the blank lines that separate it from the
+// previous entry.
+func entryStart(lines []string, n int) int {
+	at := n - 1
+	for at > 0 && strings.HasPrefix(strings.TrimSpace(lines[at-1]), "#") {
+		at--
+	}
+	for at > 0 && strings.TrimSpace(lines[at-1]) == "" {
+		at--
+	}
+	return at
+}
+
+// entryBounds returns the line range [start, end) of the match entry whose
+// `- ` item is on 1-based line n (its Match.Line) in content, as 0-based
+// indexes into its lines.
+func entryBounds(content []byte, n int) (start, end int, err error) {
+	matches, err := parseMatches(content)
+	if err != nil {
+		return 0, 0, err
+	}
+	lines := strings.Split(string(content), "\n")
+	for i, m := range matches {
+		if m.Line != n {
+			continue
+		}
+		end := len(lines)
+		if i+1 < len(matches) {
+			end = entryStart(lines, matches[i+1].Line)
+		}
+		return entryStart(lines, m.Line), end, nil
+	}
+	return 0, 0, fmt.Errorf("no match entry on line %d", n)
+}
+
+// matchEntryText returns the lines of the match entry on line n of the file
+// at path, trimmed of surrounding blank lines.
+func matchEntryText(path string, n int) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	start, end, err := entryBounds(b, n)
+	if err != nil {
+		return "", fmt.Errorf("%w in %s", err, path)
+	}
+	lines := strings.Split(string(b), "\n")
+	return strings.Trim(strings.Join(lines[start:end], "\n"), "\n"), nil
+}
+
+// deleteMatch removes the whole match entry on line n (its Match.Line) from
+// the file at path, keeping the header, comments and other entries as
+// written. The file is left untouched if no entry starts there or the result
+// wouldn't parse.
+func deleteMatch(path string, n int) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	start, end, err := entryBounds(b, n)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, path)
+	}
+	lines := strings.Split(string(b), "\n")
+	out := append(lines[:start:start], lines[end:]...)
+	updated := strings.Join(out, "\n")
+	if !strings.HasSuffix(updated, "\n") {
+		updated += "\n"
+	}
+	if err := validateMatchYAML([]byte(updated)); err != nil {
+		return fmt.Errorf("result would be invalid: %w", err)
+	}
+	return os.WriteFile(path, []byte(updated), 0o644)
+}
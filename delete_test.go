@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeleteMatch(t *testing.T) {
+	a := buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})
+	b := buildYAMLSnippet([]string{":b", ":bee"}, "B\nline two", SnippetOptions{ID: "1234"})
+	c := buildYAMLSnippet([]string{":c"}, "C", SnippetOptions{})
+
+	tests := []struct {
+		name, trigger, want string
+	}{
+		{"first", ":a", matchFileHeader + b + c},
+		{"middle by second trigger", ":bee", matchFileHeader + a + c},
+		{"last", ":c", matchFileHeader + a + b},
+	}
+	for _, tt := range tests {
+		p := writeMatchFixture(t, matchFileHeader+a+b+c)
+		m, err := findEntry(p, tt.trigger, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if err := deleteMatch(p, m.Line); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteMatch_OnlyEntry(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{}))
+	m, err := findEntry(p, ":a", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteMatch(p, m.Line); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != matchFileHeader {
+		t.Errorf("expected only the header, got %q", got)
+	}
+}
+
+func TestDeleteMatch_NotFound(t *testing.T) {
+	orig := matchFileHeader + buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})
+	p := writeMatchFixture(t, orig)
+	err := deleteMatch(p, 1)
+	if err == nil || !strings.Contains(err.Error(), "no match entry on line 1") {
+		t.Fatalf("expected a not-found error, got %v", err)
+	}
+	got, _ := os.ReadFile(p)
+	if string(got) != orig {
+		t.Errorf("file should be unchanged, got %q", got)
+	}
+}
+
+func TestRun_Delete(t *testing.T) {
+	a := buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})
+	b := buildYAMLSnippet([]string{":b"}, "B", SnippetOptions{})
+
+	p := writeMatchFixture(t, matchFileHeader+a+b)
+	code, stdout, _ := runCLI(t, "n\n", "--matchFile", p, "--delete", ":a")
+	if code == 0 {
+		t.Error("declining should exit non-zero")
+	}
+	if !strings.Contains(stdout, `trigger: ":a"`) {
+		t.Errorf("prompt should show the entry, got %q", stdout)
+	}
+	if got, _ := os.ReadFile(p); string(got) != matchFileHeader+a+b {
+		t.Errorf("declined delete changed the file: %q", got)
+	}
+
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "--delete", ":a", "--force")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if got, _ := os.ReadFile(p); string(got) != matchFileHeader+b {
+		t.Errorf("unexpected file after delete: %q", got)
+	}
+}
+
+func TestRun_DeleteSafeRejectsPathOutsideMatchDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLIESP_MATCH_DIR", filepath.Join(dir, "match"))
+	content := matchFileHeader + buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})
+	outside := filepath.Join(dir, "outside.yml")
+	if err := os.WriteFile(outside, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "", "--safe", "--force", "--matchFile", filepath.Join(dir, "match", "..", "outside.yml"), "--delete", ":a")
+	if code != exitPath {
+		t.Fatalf("exit=%d, want %d; stderr=%q", code, exitPath, stderr)
+	}
+	if !strings.Contains(stderr, "outside the match dir") {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+	if got, _ := os.ReadFile(outside); string(got) != content {
+		t.Errorf("file outside the match dir changed: %q", got)
+	}
+}
+
+func TestRun_DeleteBacksUp(t *testing.T) {
+	content := matchFileHeader + buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})
+	p := writeMatchFixture(t, content)
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "--delete", ":a", "--force", "--backup", "--no-gitignore")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	backups, err := filepath.Glob(p + ".bak-*")
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected one backup, got %v (err=%v)", backups, err)
+	}
+	if got, _ := os.ReadFile(backups[0]); string(got) != content {
+		t.Errorf("backup should hold the file before the delete, got %q", got)
+	}
+	if !strings.Contains(stdout, "Backed up") {
+		t.Errorf("unexpected stdout: %q", stdout)
+	}
+}
+
+func TestRun_DeleteByIDWithDuplicateTriggers(t *testing.T) {
+	first := buildYAMLSnippet([]string{":sig"}, "First", SnippetOptions{})
+	second := buildYAMLSnippet([]string{":sig"}, "Second", SnippetOptions{ID: "1111"})
+	p := writeMatchFixture(t, matchFileHeader+first+second)
+
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "--delete", "1111", "--id", "--yes")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if got, _ := os.ReadFile(p); string(got) != matchFileHeader+first {
+		t.Errorf("the tagged entry should be the one deleted, got %q", got)
+	}
+}
+
+func TestRun_DeleteByID(t *testing.T) {
+	a := buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{ID: "id-a"})
+	b := buildYAMLSnippet([]string{":b"}, "B", SnippetOptions{ID: "id-b"})
+	p := writeMatchFixture(t, matchFileHeader+a+b)
+
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "--delete", "id-b", "--id", "--force")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if got, _ := os.ReadFile(p); string(got) != matchFileHeader+a {
+		t.Errorf("unexpected file after delete: %q", got)
+	}
+	if !strings.Contains(stdout, `":b"`) {
+		t.Errorf("stdout should name the deleted trigger, got %q", stdout)
+	}
+
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--delete", "id-b", "--id", "--force")
+	if code != exitError || !strings.Contains(stderr, `no match with id "id-b"`) {
+		t.Errorf("unknown id: exit=%d stderr=%q", code, stderr)
+	}
+	if got, _ := os.ReadFile(p); string(got) != matchFileHeader+a {
+		t.Errorf("unknown id changed the file: %q", got)
+	}
+}
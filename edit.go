@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// findEntry returns the match that --edit or --delete names in the match
+// file at path: the one tagged with arg as its cliesp-id when byID is set,
+// else the first one defining arg as a trigger.
+func findEntry(path, arg string, byID bool) (Match, error) {
+	if byID {
+		return findMatchByID(path, arg)
+	}
+	matches, err := parseMatchFile(path)
+	if err != nil {
+		return Match{}, err
+	}
+	for _, m := range matches {
+		for _, t := range m.AllTriggers() {
+			if t == arg {
+				return m, nil
+			}
+		}
+	}
+	return Match{}, fmt.Errorf("trigger %q not found in %s", arg, path)
+}
+
+// openAtLineArgs returns the arguments that make opener open path at line:
+// `+LINE path` for terminal editors such as vim and nano, `--goto path:LINE`
+// for VS Code and `path:LINE` for editors that take that form. Unknown
+// openers just get path, so the file opens at the top.
+func openAtLineArgs(opener, path string, line int) []string {
+	fields := strings.Fields(opener)
+	if len(fields) == 0 || line <= 0 {
+		return []string{path}
+	}
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(fields[0])), ".exe")
+	n := strconv.Itoa(line)
+	switch name {
+	case "vi", "vim", "nvim", "gvim", "mvim", "nano", "emacs", "emacsclient", "micro", "kak", "joe", "ne":
+		return []string{"+" + n, path}
+	case "code", "code-insiders", "codium", "cursor":
+		return []string{"--goto", path + ":" + n}
+	case "subl", "hx", "helix", "zed":
+		return []string{path + ":" + n}
+	default:
+		return []string{path}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindEntry(t *testing.T) {
+	p := writeMatchFixture(t, "matches:\n"+
+		"  - trigger: \":a\"\n    replace: \"A\"\n"+
+		"  - triggers: [\":b\", \":c\"]\n    replace: |\n      B\n      C\n"+
+		"  # cliesp-id: id-a\n  - trigger: \":a\"\n    replace: \"A2\"\n")
+	for trigger, want := range map[string]int{":a": 2, ":c": 4} {
+		m, err := findEntry(p, trigger, false)
+		if err != nil {
+			t.Fatalf("findEntry(%q) error: %v", trigger, err)
+		}
+		if m.Line != want {
+			t.Errorf("findEntry(%q) line = %d, want %d", trigger, m.Line, want)
+		}
+	}
+	if m, err := findEntry(p, "id-a", true); err != nil || m.Line != 9 {
+		t.Errorf("findEntry by id = line %d, %v; want line 9", m.Line, err)
+	}
+	if _, err := findEntry(p, ":missing", false); err == nil || !strings.Contains(err.Error(), `trigger ":missing" not found`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOpenAtLineArgs(t *testing.T) {
+	tests := []struct {
+		opener string
+		want   string
+	}{
+		{"vim", "+12 /m/x.yml"},
+		{"/usr/local/bin/nvim -p", "+12 /m/x.yml"},
+		{"code -w", "--goto /m/x.yml:12"},
+		{"Code.exe", "--goto /m/x.yml:12"},
+		{"subl", "/m/x.yml:12"},
+		{"open", "/m/x.yml"},
+		{"", "/m/x.yml"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(openAtLineArgs(tt.opener, "/m/x.yml", 12), " "); got != tt.want {
+			t.Errorf("openAtLineArgs(%q) = %q, want %q", tt.opener, got, tt.want)
+		}
+	}
+}
+
+func TestRun_EditUnknownTrigger(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "--edit", ":nope")
+	if code != exitError || !strings.Contains(stderr, `trigger ":nope" not found`) {
+		t.Errorf("exit=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestRun_EditByID(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{ID: "id-a"}))
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "--edit", "id-nope", "--id")
+	if code != exitError || !strings.Contains(stderr, `no match with id "id-nope"`) {
+		t.Errorf("exit=%d stderr=%q", code, stderr)
+	}
+
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--id")
+	if code != exitUsage || !strings.Contains(stderr, "--id needs --edit or --delete") {
+		t.Errorf("--id alone: exit=%d stderr=%q", code, stderr)
+	}
+}
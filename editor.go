@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultEditor is used when neither VISUAL nor EDITOR is set.
+const defaultEditor = "vi"
+
+// editorCommand returns the user's editor, preferring VISUAL over EDITOR.
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if e := strings.TrimSpace(os.Getenv(name)); e != "" {
+			return e
+		}
+	}
+	return defaultEditor
+}
+
+// editText writes initial to a temp file, opens it in editor and returns the
+// saved contents minus a single trailing newline. Like openers, an editor
+// with spaces (e.g. "code -w") is split into command and args.
+func editText(editor, initial string) (string, error) {
+	parts := strings.Fields(editor)
+	if len(parts) == 0 {
+		return "", fmt.Errorf("invalid editor command")
+	}
+	f, err := os.CreateTemp("", "cliesp-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(initial); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command(parts[0], append(parts[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running editor %q: %w", editor, err)
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// replaceFromEditorTemplate opens the editor pre-populated with the template
+// at path so its placeholders can be filled in by hand.
+func replaceFromEditorTemplate(editor, path string) (string, error) {
+	initial, err := readIncludeFile(path)
+	if err != nil {
+		return "", err
+	}
+	return editText(editor, initial+"\n")
+}
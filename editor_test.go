@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeEditor writes a shell script that appends line to the file it is given.
+func fakeEditor(t *testing.T, line string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "editor.sh")
+	script := "#!/bin/sh\nprintf '%s\\n' '" + line + "' >> \"$1\"\n"
+	if err := os.WriteFile(p, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestEditorCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+	if got := editorCommand(); got != defaultEditor {
+		t.Errorf("fallback = %q, want %q", got, defaultEditor)
+	}
+	t.Setenv("EDITOR", "nano")
+	if got := editorCommand(); got != "nano" {
+		t.Errorf("EDITOR = %q, want nano", got)
+	}
+	t.Setenv("VISUAL", "code -w")
+	if got := editorCommand(); got != "code -w" {
+		t.Errorf("VISUAL should win, got %q", got)
+	}
+}
+
+func TestReplaceFromEditorTemplate(t *testing.T) {
+	tpl := filepath.Join(t.TempDir(), "letter.txt")
+	if err := os.WriteFile(tpl, []byte("Dear NAME,\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := replaceFromEditorTemplate(fakeEditor(t, "Best, Kev"), tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Dear NAME,\nBest, Kev"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRun_ReplaceFromEditorTemplate(t *testing.T) {
+	tpl := filepath.Join(t.TempDir(), "sig.txt")
+	if err := os.WriteFile(tpl, []byte("Regards,\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VISUAL", fakeEditor(t, "Kev"))
+	p := writeMatchFixture(t, matchFileHeader)
+
+	code, _, stderr := runCLI(t, ":sig\n", "--matchFile", p, "--replace-from-editor-template", tpl)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "replace: |\n      Regards,\n      Kev\n") {
+		t.Errorf("edited template not used, file:\n%s", b)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// entryFlags are the flag values that need parsing before they can shape an
+// entry. run parses them up front so a bad value fails before any prompt.
+type entryFlags struct {
+	transforms []func(string) string
+	extras     []Extra
+	vars       []Var
+	defines    map[string]string
+	engine     string
+}
+
+// parseEntryFlags parses --transform, --extra, --var, --engine and
+// --define/--set. The engine and date format fall back to the config.
+func parseEntryFlags(flags cliFlags, cfg AppConfig) (entryFlags, error) {
+	var ef entryFlags
+	var err error
+	if ef.transforms, err = parseTransforms(flags.transform); err != nil {
+		return ef, err
+	}
+	for _, arg := range flags.extra {
+		e, err := parseExtra(arg)
+		if err != nil {
+			return ef, err
+		}
+		ef.extras = append(ef.extras, e)
+	}
+	for _, arg := range flags.vars {
+		v, err := parseVarFlag(arg, cfg.DefaultDateFormat)
+		if err != nil {
+			return ef, err
+		}
+		ef.vars = append(ef.vars, v)
+	}
+	ef.engine = cfg.TemplateEngine
+	if flags.engine != "" {
+		ef.engine = flags.engine
+	}
+	if _, err := placeholderFunc(ef.engine); err != nil {
+		return ef, err
+	}
+	ef.defines = make(map[string]string, len(flags.define))
+	for _, arg := range flags.define {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return ef, fmt.Errorf("invalid --define %q, want key=value", arg)
+		}
+		ef.defines[strings.TrimSpace(k)] = v
+	}
+	return ef, nil
+}
+
+// readTriggers returns the triggers from --trigger, the arguments (for
+// --regex) or a prompt written to out.
+func readTriggers(in *bufio.Reader, out io.Writer, flags cliFlags, args []string) ([]string, error) {
+	switch {
+	case len(flags.trigger) > 0:
+		return append([]string(nil), flags.trigger...), nil
+	case flags.regex && len(args) > 0:
+		return args, nil
+	case flags.regex, flags.rawTrigger:
+		msg := "trigger? (taken verbatim, including spaces): "
+		if flags.regex {
+			msg = "regex? "
+		}
+		fmt.Fprint(out, msg)
+		line, _, err := readLine(in)
+		if err != nil || line == "" {
+			return nil, err
+		}
+		return []string{line}, nil
+	default:
+		line, err := prompt(in, out, "triggers? (space separated list of strings): ")
+		if err != nil {
+			return nil, err
+		}
+		return parseTriggers(line), nil
+	}
+}
+
+// prepareTriggers checks the triggers and applies the configured case and
+// prefix to them. A regex must be a single pattern that compiles, and is
+// left as written. Failures are reported on stderr and returned as an exit
+// code.
+func prepareTriggers(stderr io.Writer, triggers []string, flags cliFlags, cfg AppConfig) ([]string, int) {
+	if len(triggers) == 0 {
+		fmt.Fprintln(stderr, "no triggers provided, exiting")
+		return nil, exitError
+	}
+	if flags.regex {
+		if len(triggers) > 1 {
+			fmt.Fprintln(stderr, "--regex takes a single pattern per match")
+			return nil, exitUsage
+		}
+		if _, err := regexp.Compile(triggers[0]); err != nil {
+			fmt.Fprintln(stderr, "invalid regex:", err)
+			return nil, exitValidation
+		}
+	}
+	caseMode := cfg.TriggerCase
+	if flags.triggerCase != "" {
+		caseMode = flags.triggerCase
+	}
+	if flags.regex {
+		// Changing case would change what the pattern matches.
+		caseMode = ""
+	}
+	triggers, err := transformTriggerCase(triggers, caseMode)
+	if err != nil {
+		fmt.Fprintln(stderr, "invalid trigger case:", err)
+		return nil, exitUsage
+	}
+	prefix := cfg.TriggerPrefix
+	if flags.prefixSet {
+		prefix = flags.prefix
+	}
+	if !flags.regex {
+		triggers = applyTriggerPrefix(triggers, prefix)
+	}
+	maxLen := cfg.MaxTriggerLength
+	if flags.maxTriggerLength > 0 {
+		maxLen = flags.maxTriggerLength
+	}
+	if err := validateTriggers(triggers, maxLen); err != nil {
+		fmt.Fprintln(stderr, "invalid trigger:", err)
+		return nil, exitValidation
+	}
+	if !flags.noValidate {
+		for _, t := range triggers {
+			if err := validateTrigger(t); err != nil {
+				fmt.Fprintln(stderr, "invalid trigger:", err)
+				return nil, exitValidation
+			}
+		}
+	}
+	return triggers, exitOK
+}
+
+// readReplace returns the replacement from whichever source the flags
+// select, prompting on out (in the given multiline mode) when none does.
+// Sources that expand when the match fires, such as --timestamp and
+// --include-file, return the var they need along with a replacement that
+// uses it.
+func readReplace(in *bufio.Reader, out, stderr io.Writer, flags cliFlags, cfg AppConfig, mode string) (string, []Var, error) {
+	var replaceStr string
+	var vars []Var
+	var err error
+	switch {
+	case flags.replaceSet && flags.replace == "-":
+		replaceStr, err = readAllInput(in)
+	case flags.replaceSet:
+		replaceStr, err = resolveReplaceArg(flags.replace)
+	case len(flags.line) > 0:
+		replaceStr = strings.Join(flags.line, "\n")
+	case flags.replaceFromURL != "":
+		replaceStr, err = fetchReplace(httpClient, flags.replaceFromURL)
+	case flags.replaceFromCmd != "":
+		replaceStr, err = replaceFromCommand(flags.replaceFromCmd)
+	case flags.editorTemplate != "":
+		replaceStr, err = replaceFromEditorTemplate(editorCommand(), flags.editorTemplate)
+	case flags.snip != "":
+		if cfg.SnippetLibrary == "" {
+			err = errors.New("--snip needs snippet_library to be configured")
+		} else {
+			replaceStr, err = loadSnippet(cfg.SnippetLibrary, flags.snip)
+		}
+	case flags.clipboard:
+		replaceStr, err = readClipboard()
+		if errors.Is(err, errNoClipboardTool) {
+			fmt.Fprintln(stderr, "warning: no clipboard tool found (pbpaste, xclip, xsel, wl-paste or powershell); enter the replacement instead")
+			replaceStr, err = promptMultiline(in, out, "replace with? (supports multiline): ", mode, flags.numberedInput)
+		}
+	case flags.fromHistory:
+		var entries []string
+		var histPath string
+		if histPath, err = historyFilePath(); err == nil {
+			entries, err = loadHistory(histPath)
+		}
+		if err == nil {
+			replaceStr, err = pickFromHistory(in, out, entries, resolvePreviewWidth(flags.previewWidth, cfg.PreviewWidth))
+		}
+	case flags.timestamp.enabled:
+		v := dateVar(timestampVarName, flags.timestamp.value, cfg.DefaultDateFormat)
+		vars = append(vars, v)
+		replaceStr = "{{" + v.Name + "}}"
+	case flags.includeFile != "" && flags.inline:
+		replaceStr, err = readIncludeFile(flags.includeFile)
+	case flags.includeFile != "":
+		var v Var
+		if v, err = includeFileVar(flags.includeFile); err == nil {
+			vars = append(vars, v)
+			replaceStr = "{{" + v.Name + "}}"
+		}
+	default:
+		replaceStr, err = promptMultiline(in, out, "replace with? (supports multiline): ", mode, flags.numberedInput)
+	}
+	return replaceStr, vars, err
+}
+
+// builtEntry is a snippet ready to write, along with the final replacement
+// and vars it was built from.
+type builtEntry struct {
+	Text    string
+	Replace string
+	Vars    []Var
+}
+
+// buildEntry turns the triggers and the replacement as read into the
+// snippet to write. It applies every flag that shapes the entry: --var,
+// replacement filters, --define values, indentation and code fences, the
+// match options, an ID, a template and the configured formatter. run and
+// --output-only both build through it, so what one prints is what the other
+// writes. Failures are reported on stderr and returned as an exit code.
+func buildEntry(stderr io.Writer, triggers []string, replaceStr string, vars []Var, flags cliFlags, ef entryFlags, cfg AppConfig) (builtEntry, int) {
+	for _, v := range ef.vars {
+		if !strings.Contains(replaceStr, "{{"+v.Name+"}}") {
+			fmt.Fprintf(stderr, "warning: the replacement doesn't use var %q (add {{%s}})\n", v.Name, v.Name)
+		}
+	}
+	vars = append(vars, ef.vars...)
+
+	if flags.dedent {
+		replaceStr = dedent(replaceStr)
+	}
+	replaceStr = applyTransforms(replaceStr, ef.transforms)
+	if flags.straightenQuotes {
+		replaceStr = straightenQuotes(replaceStr)
+	}
+	if len(ef.defines) > 0 {
+		// The engine was validated by parseEntryFlags.
+		replaceStr, _ = interpolate(replaceStr, ef.defines, ef.engine)
+	}
+
+	if err := checkUTF8(replaceStr, flags.allowInvalidUTF8); err != nil {
+		fmt.Fprintln(stderr, err)
+		return builtEntry{}, exitValidation
+	}
+	replaceStr, err := checkEmptyReplace(replaceStr, flags.allowEmpty)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return builtEntry{}, exitValidation
+	}
+
+	// Guess the language before indenting, which would hide line starts.
+	lang := flags.code.value
+	if lang == "" && flags.autoCode {
+		lang = codeLanguage(replaceStr, flags.langHint)
+	}
+	if flags.indent > 0 {
+		replaceStr = indentLines(replaceStr, flags.indent)
+	}
+	if flags.code.enabled || flags.autoCode {
+		replaceStr = wrapCode(replaceStr, lang)
+	}
+
+	opts := SnippetOptions{
+		Vars:              vars,
+		DisableInjectVars: !flags.injectVars,
+		Extends:           flags.extends,
+		SortTriggers:      flags.sortTriggers,
+		Extra:             ef.extras,
+		PropagateCase:     flags.propagateCase,
+		Word:              flags.word,
+		Label:             flags.label,
+		Regex:             flags.regex,
+		FoldAt:            flags.foldAt,
+	}
+	if flags.withID {
+		if opts.ID, err = newMatchID(); err != nil {
+			fmt.Fprintln(stderr, "error generating id:", err)
+			return builtEntry{}, exitError
+		}
+	}
+	entry := buildYAMLSnippet(triggers, replaceStr, opts)
+
+	if flags.template != "" || flags.templateFile != "" {
+		var tplPath string
+		if flags.template != "" {
+			var dir string
+			if dir, err = configDir(); err == nil {
+				tplPath, err = resolveTemplate(dir, flags.template)
+			}
+		} else {
+			tplPath, err = expandHome(flags.templateFile)
+		}
+		if err == nil {
+			values := map[string]string{"trigger": triggers[0], "replace": replaceStr}
+			for k, v := range ef.defines {
+				if _, ok := values[k]; !ok {
+					values[k] = v
+				}
+			}
+			entry, err = renderTemplateFile(tplPath, values, ef.engine)
+		}
+		if err == nil && flags.strict {
+			var names []string
+			if names, err = unresolvedPlaceholders(entry, ef.engine); err == nil && len(names) > 0 {
+				err = fmt.Errorf("unresolved placeholders: %s (set them with --set)", strings.Join(names, ", "))
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "error rendering template:", err)
+			return builtEntry{}, exitValidation
+		}
+	}
+
+	if cfg.FormatterCommand != "" {
+		if entry, err = formatSnippet(cfg.FormatterCommand, entry); err != nil {
+			fmt.Fprintln(stderr, "error running formatter:", err)
+			return builtEntry{}, exitExternal
+		}
+	}
+	return builtEntry{Text: entry, Replace: replaceStr, Vars: vars}, exitOK
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// runCommand runs an external command with stdin (nil for none) and returns
+// its stdout. Whatever it writes to stderr is only used to explain a
+// failure, so warnings never end up in the output. Tests swap it for a fake
+// so no real espanso, formatter or clipboard tool is needed.
+var runCommand = func(stdin io.Reader, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.Bytes(), fmt.Errorf("%w: %s", err, msg)
+		}
+		return stdout.Bytes(), err
+	}
+	return stdout.Bytes(), nil
+}
+
+// lookPath finds commands on PATH. Tests swap it along with runCommand.
+var lookPath = exec.LookPath
+
+// defaultReloadCommand restarts espanso so it picks up changed match files.
+const defaultReloadCommand = "espanso restart"
+
+// reloadDisabled is the reload_command value that turns reloading off.
+const reloadDisabled = "none"
+
+// errReloadNotFound means the reload command isn't installed, which only
+// warrants a warning since the match was written anyway.
+var errReloadNotFound = errors.New("reload command not found in PATH")
+
+// runReload runs the reload command. Like an opener, a command with spaces
+// (e.g. "espanso restart") is split into command and args.
+func runReload(cmd string) error {
+	parts := strings.Fields(cmd)
+	if len(parts) == 0 {
+		return fmt.Errorf("invalid reload command")
+	}
+	if _, err := lookPath(parts[0]); err != nil {
+		return fmt.Errorf("%w: %s", errReloadNotFound, parts[0])
+	}
+	if _, err := runCommand(nil, parts[0], parts[1:]...); err != nil {
+		return fmt.Errorf("%s: %w", cmd, err)
+	}
+	return nil
+}
+
+// espansoRunning reports whether espanso is running according to
+// `espanso status`. That command exits non-zero, and may report on stderr,
+// when espanso is stopped, so its output and error are checked first.
+func espansoRunning() (bool, error) {
+	out, err := runCommand(nil, "espanso", "status")
+	status := strings.ToLower(string(out))
+	if err != nil {
+		status += "\n" + strings.ToLower(err.Error())
+	}
+	switch {
+	case strings.Contains(status, "not running"):
+		return false, nil
+	case strings.Contains(status, "running"):
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("espanso status: %w", err)
+	}
+	return false, fmt.Errorf("espanso status: unexpected output %q", strings.TrimSpace(string(out)))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunCommand_KeepsStderrOutOfOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	out, err := runCommand(strings.NewReader("in"), "sh", "-c", "cat; echo warning >&2")
+	if err != nil || string(out) != "in" {
+		t.Errorf("got %q, %v; want only stdout", out, err)
+	}
+	out, err = runCommand(nil, "sh", "-c", "echo partial; echo boom >&2; exit 3")
+	if err == nil || !strings.HasSuffix(err.Error(), ": boom") || strings.Contains(err.Error(), "partial") {
+		t.Errorf("error should carry stderr only, got %v", err)
+	}
+	if string(out) != "partial\n" {
+		t.Errorf("stdout on failure = %q", out)
+	}
+}
+
+// fakeRunner replaces runCommand for the duration of a test, answering with
+// out as stdout and err, and records each invocation as a single
+// "name arg..." string.
+func fakeRunner(t *testing.T, out string, err error) *[]string {
+	t.Helper()
+	var calls []string
+	orig := runCommand
+	runCommand = func(stdin io.Reader, name string, args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(append([]string{name}, args...), " "))
+		return []byte(out), err
+	}
+	origLook := lookPath
+	lookPath = func(name string) (string, error) { return name, nil }
+	t.Cleanup(func() { runCommand, lookPath = orig, origLook })
+	return &calls
+}
+
+func TestRun_ReloadsAfterAppend(t *testing.T) {
+	calls := fakeRunner(t, "espanso is running\n", nil)
+	p := writeMatchFixture(t, matchFileHeader)
+
+	code, stdout, stderr := runCLI(t, ":a :b :c\nShared\n\n", "--matchFile", p, "--reload")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if len(*calls) != 2 || (*calls)[1] != "espanso restart" {
+		t.Errorf("expected a status check and exactly one espanso restart, got %q", *calls)
+	}
+	if !strings.Contains(stdout, "Appended 3 trigger(s)") {
+		t.Errorf("unexpected stdout: %q", stdout)
+	}
+}
+
+func TestRun_ReloadFailureStillSucceeds(t *testing.T) {
+	for name, out := range map[string]string{
+		"status check fails": "",
+		"restart fails":      "espanso is running\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			fakeRunner(t, out, errors.New("exit status 1"))
+			p := writeMatchFixture(t, matchFileHeader)
+			code, stdout, stderr := runCLI(t, ":a\nA\n\n", "--matchFile", p, "--reload")
+			if code != 0 {
+				t.Fatalf("the match was written, so the run should succeed: exit=%d stderr=%q", code, stderr)
+			}
+			if !strings.Contains(stderr, "warning:") || !strings.Contains(stdout, "Appended 1 trigger(s)") {
+				t.Errorf("expected a warning after the append, got stdout %q, stderr %q", stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestAppendEntry_DoesNotReload(t *testing.T) {
+	calls := fakeRunner(t, "", nil)
+	p := writeMatchFixture(t, matchFileHeader)
+	for _, tr := range []string{":a", ":b", ":c"} {
+		if err := appendEntry(p, "", buildYAMLSnippet([]string{tr}, "x", SnippetOptions{})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*calls) != 0 {
+		t.Errorf("appendEntry should not run commands, got %q", *calls)
+	}
+	matches, err := parseMatchFile(p)
+	if err != nil || len(matches) != 3 {
+		t.Errorf("expected 3 matches, got %d (err=%v)", len(matches), err)
+	}
+}
+
+func TestRun_NoReloadWithoutFlag(t *testing.T) {
+	calls := fakeRunner(t, "", nil)
+	p := writeMatchFixture(t, matchFileHeader)
+	if code, _, stderr := runCLI(t, ":a\nA\n\n", "--matchFile", p); code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("expected no reload, got %q", *calls)
+	}
+}
+
+func TestEspansoRunning(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{"running", "espanso is running\n", nil, true, false},
+		{"not running", "espanso is not running\n", errors.New("exit status 1"), false, false},
+		{"not running on stderr", "", errors.New("exit status 1: espanso is not running"), false, false},
+		{"missing binary", "", errors.New("executable file not found"), false, true},
+		{"unexpected output", "huh?", nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeRunner(t, tt.out, tt.err)
+			got, err := espansoRunning()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("running = %v, want %v", got, tt.want)
+			}
+			if len(*calls) != 1 || (*calls)[0] != "espanso status" {
+				t.Errorf("unexpected calls %q", *calls)
+			}
+		})
+	}
+}
+
+func TestRun_ReloadSkippedWhenNotRunning(t *testing.T) {
+	calls := fakeRunner(t, "espanso is not running\n", errors.New("exit status 1"))
+	p := writeMatchFixture(t, matchFileHeader)
+
+	code, stdout, stderr := runCLI(t, ":a\nA\n\n", "--matchFile", p, "--reload")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if len(*calls) != 1 || !strings.Contains(stdout, "skipping reload") {
+		t.Errorf("expected only a status check, got calls %q, stdout %q", *calls, stdout)
+	}
+}
+
+func TestRunReload(t *testing.T) {
+	calls := fakeRunner(t, "", nil)
+	if err := runReload("espanso   restart"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "espanso restart" {
+		t.Errorf("unexpected calls %q", *calls)
+	}
+
+	lookPath = func(name string) (string, error) { return "", errors.New("not found") }
+	if err := runReload("espanso restart"); !errors.Is(err, errReloadNotFound) {
+		t.Errorf("expected errReloadNotFound, got %v", err)
+	}
+	if err := runReload("  "); err == nil {
+		t.Error("expected an error for an empty command")
+	}
+}
+
+func TestRun_ReloadWarnsWhenNotInstalled(t *testing.T) {
+	calls := fakeRunner(t, "", nil)
+	lookPath = func(name string) (string, error) { return "", errors.New("not found") }
+	p := writeMatchFixture(t, matchFileHeader)
+
+	code, _, stderr := runCLI(t, ":a\nA\n\n", "--matchFile", p, "--reload")
+	if code != 0 {
+		t.Fatalf("a missing espanso should not fail the append: exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stderr, "warning:") || len(*calls) != 0 {
+		t.Errorf("expected only a warning, got stderr %q, calls %q", stderr, *calls)
+	}
+}
+
+func TestRun_ReloadCommandFromConfig(t *testing.T) {
+	calls := fakeRunner(t, "", nil)
+	t.Setenv("CLIESP_RELOAD", "true")
+	t.Setenv("CLIESP_RELOAD_COMMAND", "systemctl --user restart espanso")
+	p := writeMatchFixture(t, matchFileHeader)
+	if code, _, stderr := runCLI(t, ":a\nA\n\n", "--matchFile", p); code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "systemctl --user restart espanso" {
+		t.Errorf("unexpected calls %q", *calls)
+	}
+
+	*calls = nil
+	t.Setenv("CLIESP_RELOAD_COMMAND", reloadDisabled)
+	if code, _, stderr := runCLI(t, ":b\nB\n\n", "--matchFile", p, "--reload"); code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("reload_command: none should disable reloading, got %q", *calls)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+// Exit codes returned by run. Scripts can rely on these to tell failure
+// classes apart; every return path in run uses one of them.
+const (
+	exitOK         = 0 // success, including "nothing to do"
+	exitError      = 1 // generic failure: aborted, bad input, unreadable match file
+	exitUsage      = 2 // invalid or conflicting flags
+	exitConfig     = 3 // the config or default_flags couldn't be loaded
+	exitPath       = 4 // the match file path couldn't be resolved or isn't allowed
+	exitWrite      = 5 // creating, backing up or writing the match file failed
+	exitValidation = 6 // a trigger, replacement or match file failed validation
+	exitExternal   = 7 // an external command (opener, formatter) failed
+)
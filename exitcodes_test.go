@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRun_ExitCodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		input string
+		args  func(t *testing.T) []string
+		want  int
+	}{
+		{
+			name: "config",
+			env:  map[string]string{"CLIESP_DEFAULT_FLAGS": `--matchFile "unterminated`},
+			args: func(t *testing.T) []string { return nil },
+			want: exitConfig,
+		},
+		{
+			name: "usage",
+			args: func(t *testing.T) []string { return []string{"--open", "--openDir"} },
+			want: exitUsage,
+		},
+		{
+			name:  "path",
+			input: ":a\nA\n\n",
+			args: func(t *testing.T) []string {
+				dir := t.TempDir()
+				t.Setenv("CLIESP_MATCH_DIR", filepath.Join(dir, "match"))
+				return []string{"--safe", "--matchFile", filepath.Join(dir, "elsewhere.yml")}
+			},
+			want: exitPath,
+		},
+		{
+			name:  "write",
+			input: ":a\nA\n\n",
+			args: func(t *testing.T) []string {
+				// A regular file can't be used as a directory.
+				notDir := writeMatchFixture(t, matchFileHeader)
+				return []string{"--yes", "--matchFile", filepath.Join(notDir, "sub.yml")}
+			},
+			want: exitWrite,
+		},
+		{
+			name:  "validation",
+			input: ":toolong\n",
+			args: func(t *testing.T) []string {
+				return []string{"--max-trigger-length", "3", "--matchFile", writeMatchFixture(t, matchFileHeader)}
+			},
+			want: exitValidation,
+		},
+		{
+			name:  "external",
+			env:   map[string]string{"CLIESP_FORMATTER_COMMAND": "cliesp-no-such-formatter"},
+			input: ":a\nA\n\n",
+			args: func(t *testing.T) []string {
+				return []string{"--matchFile", writeMatchFixture(t, matchFileHeader)}
+			},
+			want: exitExternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			code, _, stderr := runCLI(t, tt.input, tt.args(t)...)
+			if code != tt.want {
+				t.Errorf("exit=%d, want %d (stderr=%q)", code, tt.want, stderr)
+			}
+		})
+	}
+}
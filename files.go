@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileInfo is a match file and its last modification time.
+type FileInfo struct {
+	Path    string
+	ModTime time.Time
+}
+
+// isMatchFileName reports whether name has a YAML extension.
+func isMatchFileName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// recentFiles lists the YAML match files directly inside dir, newest first.
+// A missing dir yields an empty list rather than an error.
+func recentFiles(dir string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []FileInfo
+	for _, e := range entries {
+		if e.IsDir() || !isMatchFileName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, FileInfo{Path: filepath.Join(dir, e.Name()), ModTime: info.ModTime()})
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].ModTime.After(files[j].ModTime) })
+	return files, nil
+}
+
+// matchCandidates returns the match files directly inside dir, sorted by
+// name. A missing dir has none.
+func matchCandidates(dir string) ([]string, error) {
+	files, err := recentFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// autoTarget returns the only match file in dir, if there is exactly one.
+func autoTarget(dir string) (string, bool, error) {
+	paths, err := matchCandidates(dir)
+	if err != nil || len(paths) != 1 {
+		return "", false, err
+	}
+	return paths[0], true, nil
+}
+
+// chooseTarget lists candidates as a numbered menu and returns the chosen
+// one, or def when the answer is empty. It asks again until the answer is
+// valid.
+func chooseTarget(in *bufio.Reader, out io.Writer, candidates []string, def string) (string, error) {
+	fmt.Fprintf(out, "%s doesn't exist. Existing match files:\n", def)
+	for i, c := range candidates {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, filepath.Base(c))
+	}
+	for {
+		ans, err := prompt(in, out, fmt.Sprintf("use which file? (Enter to create %s) ", filepath.Base(def)))
+		if err != nil {
+			return "", err
+		}
+		if ans == "" {
+			return def, nil
+		}
+		i, err := parseHistoryChoice(ans, len(candidates))
+		if err == nil {
+			return candidates[i], nil
+		}
+		fmt.Fprintln(out, err)
+	}
+}
+
+// printRecentFiles writes one line per file with its modification time.
+func printRecentFiles(w io.Writer, files []FileInfo) {
+	if len(files) == 0 {
+		fmt.Fprintln(w, "no match files found")
+		return
+	}
+	for _, f := range files {
+		fmt.Fprintf(w, "%s  %s\n", f.ModTime.Format("2006-01-02 15:04"), filepath.Base(f.Path))
+	}
+}
+
+// normalizeFinalNewline rewrites the file at path so it ends with exactly one
+// newline, dropping any blank lines at EOF. Empty files are left alone, and
+// the file is only written when its content changes.
+func normalizeFinalNewline(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	lines := strings.Split(string(b), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	normalized := strings.Join(lines, "\n") + "\n"
+	if normalized == string(b) {
+		return nil
+	}
+	return os.WriteFile(path, []byte(normalized), 0o644)
+}
+
+// insertPoint is where a new entry is written: at the end of Section when
+// set (--group), at the sorted position of SortKey when set
+// (--append-sorted), or else at the end of the file.
+type insertPoint struct {
+	Section string
+	SortKey string
+}
+
+// contentBefore returns the part of content that precedes where at puts a
+// new entry. A section that doesn't exist yet has nothing before the entry,
+// since the entry opens it.
+func contentBefore(content []byte, at insertPoint) ([]byte, error) {
+	lines := strings.Split(string(content), "\n")
+	switch {
+	case at.Section != "":
+		end, ok := sectionEnd(lines, at.Section)
+		if !ok {
+			return nil, nil
+		}
+		return []byte(strings.Join(lines[:end], "\n")), nil
+	case at.SortKey != "":
+		n, ok, err := sortedInsertLine(content, at.SortKey)
+		if err != nil || !ok {
+			return content, err
+		}
+		return []byte(strings.Join(lines[:n], "\n")), nil
+	}
+	return content, nil
+}
+
+// lastEntryEquals reports whether the entry just before where at puts a new
+// one in the file at path is snippet, ignoring trailing newlines on both. It
+// catches the same append being run twice in a row. A missing file never
+// matches.
+func lastEntryEquals(path string, at insertPoint, snippet string) (bool, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	want := strings.TrimRight(snippet, "\n")
+	if strings.TrimSpace(want) == "" {
+		return false, nil
+	}
+	before, err := contentBefore(b, at)
+	if err != nil {
+		return false, err
+	}
+	return strings.HasSuffix(strings.TrimRight(string(before), "\n"), want), nil
+}
+
+// appendEntry writes entry to the end of the match file at path, or to the
+// end of section when one is given. It only writes; reloading espanso is left
+// to the caller.
+func appendEntry(path, section, entry string) error {
+	if section != "" {
+		return appendUnderSection(path, section, entry)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(entry); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// checkWithinDir returns an error unless path lies inside dir. Both are made
+// absolute and cleaned first, so `..` segments can't escape dir.
+func checkWithinDir(path, dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return fmt.Errorf("%s is outside the match dir %s", path, dir)
+	}
+	return nil
+}
+
+// collectDirTriggers parses every match file under dir and returns the
+// triggers each defines, keyed by path relative to dir.
+func collectDirTriggers(dir string) (map[string][]string, error) {
+	byFile := make(map[string][]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isMatchFileName(d.Name()) {
+			return nil
+		}
+		matches, err := parseMatchFile(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		var triggers []string
+		for _, m := range matches {
+			triggers = append(triggers, m.AllTriggers()...)
+		}
+		byFile[filepath.ToSlash(rel)] = triggers
+		return nil
+	})
+	return byFile, err
+}
+
+// printTriggersByFile writes each file name as a header followed by its
+// triggers, indented. Files and the triggers within each are sorted.
+func printTriggersByFile(w io.Writer, byFile map[string][]string) {
+	if len(byFile) == 0 {
+		fmt.Fprintln(w, "no match files found")
+		return
+	}
+	for i, name := range sortedKeys(byFile) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\n", name)
+		triggers := append([]string(nil), byFile[name]...)
+		sort.Strings(triggers)
+		if len(triggers) == 0 {
+			fmt.Fprintln(w, "  (no matches)")
+		}
+		for _, t := range triggers {
+			fmt.Fprintf(w, "  %s\n", t)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecentFiles_NewestFirst(t *testing.T) {
+	tdir := t.TempDir()
+	now := time.Now()
+	names := []string{"old.yml", "newest.yaml", "middle.yml", "notes.txt"}
+	ages := []time.Duration{3 * time.Hour, 0, time.Hour, 0}
+	for i, n := range names {
+		p := filepath.Join(tdir, n)
+		if err := os.WriteFile(p, []byte("matches:\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mt := now.Add(-ages[i])
+		if err := os.Chtimes(p, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := recentFiles(tdir)
+	if err != nil {
+		t.Fatalf("recentFiles error: %v", err)
+	}
+	want := []string{"newest.yaml", "middle.yml", "old.yml"}
+	if len(files) != len(want) {
+		t.Fatalf("expected %d files, got %d: %+v", len(want), len(files), files)
+	}
+	for i, f := range files {
+		if filepath.Base(f.Path) != want[i] {
+			t.Errorf("position %d: got %s want %s", i, filepath.Base(f.Path), want[i])
+		}
+	}
+}
+
+func TestRecentFiles_MissingDir(t *testing.T) {
+	files, err := recentFiles(filepath.Join(t.TempDir(), "nope"))
+	if err != nil {
+		t.Fatalf("missing dir should not error: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no files, got %+v", files)
+	}
+}
+
+func TestNormalizeFinalNewline(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "no trailing newline", in: "matches:\n  - trigger: \":a\"", want: "matches:\n  - trigger: \":a\"\n"},
+		{name: "one trailing newline", in: "matches:\n", want: "matches:\n"},
+		{name: "multiple trailing newlines", in: "matches:\n\n\n", want: "matches:\n"},
+		{name: "trailing whitespace-only lines", in: "matches:\n  \n\t\n", want: "matches:\n"},
+		{name: "empty file", in: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "cliesp.yml")
+			if err := os.WriteFile(p, []byte(tt.in), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := normalizeFinalNewline(p); err != nil {
+				t.Fatalf("normalizeFinalNewline error: %v", err)
+			}
+			b, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %q want %q", string(b), tt.want)
+			}
+		})
+	}
+}
+
+func TestLastEntryEquals(t *testing.T) {
+	entry := buildYAMLSnippet([]string{":sig"}, "Best", SnippetOptions{})
+	p := writeMatchFixture(t, matchFileHeader+entry+"\n")
+
+	same, err := lastEntryEquals(p, insertPoint{}, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !same {
+		t.Error("expected the identical last entry to match")
+	}
+
+	for _, other := range []string{
+		buildYAMLSnippet([]string{":sig"}, "Best regards", SnippetOptions{}),
+		buildYAMLSnippet([]string{":ig"}, "Best", SnippetOptions{}),
+	} {
+		if same, err := lastEntryEquals(p, insertPoint{}, other); err != nil || same {
+			t.Errorf("lastEntryEquals(%q) = %v, %v; want false", other, same, err)
+		}
+	}
+}
+
+func TestLastEntryEquals_OnlyComparesTail(t *testing.T) {
+	a := buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})
+	b := buildYAMLSnippet([]string{":b"}, "B", SnippetOptions{})
+	p := writeMatchFixture(t, matchFileHeader+a+b)
+
+	if same, _ := lastEntryEquals(p, insertPoint{}, a); same {
+		t.Error("an identical earlier entry should not count")
+	}
+	if same, err := lastEntryEquals(filepath.Join(t.TempDir(), "missing.yml"), insertPoint{}, a); err != nil || same {
+		t.Errorf("missing file: got %v, %v", same, err)
+	}
+}
+
+func TestLastEntryEquals_Section(t *testing.T) {
+	a := buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})
+	b := buildYAMLSnippet([]string{":b"}, "B", SnippetOptions{})
+	p := writeMatchFixture(t, matchFileHeader+sectionComment("One")+"\n"+a+"\n"+sectionComment("Two")+"\n"+b)
+
+	if same, err := lastEntryEquals(p, insertPoint{Section: "one"}, a); err != nil || !same {
+		t.Errorf("last entry of section One: got %v, %v", same, err)
+	}
+	if same, _ := lastEntryEquals(p, insertPoint{Section: "One"}, b); same {
+		t.Error("the file's last entry is in another section and should not count")
+	}
+	if same, err := lastEntryEquals(p, insertPoint{Section: "Three"}, b); err != nil || same {
+		t.Errorf("new section: got %v, %v", same, err)
+	}
+}
+
+func TestCheckWithinDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "match")
+	allowed := []string{
+		filepath.Join(dir, "cliesp.yml"),
+		filepath.Join(dir, "work", "base.yml"),
+		filepath.Join(dir, "work", "..", "base.yml"),
+	}
+	for _, p := range allowed {
+		if err := checkWithinDir(p, dir); err != nil {
+			t.Errorf("checkWithinDir(%q) = %v, want nil", p, err)
+		}
+	}
+	rejected := []string{
+		filepath.Join(filepath.Dir(dir), "other.yml"),
+		filepath.Join(dir, "..", "escape.yml"),
+		filepath.Join(dir, "work", "..", "..", "escape.yml"),
+		dir + "-sibling" + string(filepath.Separator) + "x.yml",
+		dir,
+	}
+	for _, p := range rejected {
+		if err := checkWithinDir(p, dir); err == nil {
+			t.Errorf("checkWithinDir(%q) = nil, want an error", p)
+		}
+	}
+}
+
+func TestPrintTriggersByFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("work.yml", matchFileHeader+
+		buildYAMLSnippet([]string{":zoom"}, "z", SnippetOptions{})+
+		buildYAMLSnippet([]string{":addr", ":adr"}, "a", SnippetOptions{}))
+	write("base.yml", matchFileHeader+buildYAMLSnippet([]string{":sig"}, "s", SnippetOptions{}))
+	write("notes.txt", "ignored")
+
+	byFile, err := collectDirTriggers(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	printTriggersByFile(&buf, byFile)
+	want := "base.yml\n  :sig\n\nwork.yml\n  :addr\n  :adr\n  :zoom\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestAutoTarget(t *testing.T) {
+	dir := t.TempDir()
+	touch := func(name string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(matchFileHeader), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, ok, err := autoTarget(dir); ok || err != nil {
+		t.Errorf("no candidates: ok=%v err=%v", ok, err)
+	}
+
+	touch("notes.txt")
+	touch("base.yml")
+	p, ok, err := autoTarget(dir)
+	if err != nil || !ok || p != filepath.Join(dir, "base.yml") {
+		t.Errorf("one candidate: got %q, %v, %v", p, ok, err)
+	}
+
+	touch("work.yaml")
+	if _, ok, err := autoTarget(dir); ok || err != nil {
+		t.Errorf("several candidates should be ambiguous: ok=%v err=%v", ok, err)
+	}
+	candidates, err := matchCandidates(dir)
+	if err != nil || len(candidates) != 2 {
+		t.Fatalf("candidates = %v, %v", candidates, err)
+	}
+	var out bytes.Buffer
+	got, err := chooseTarget(bufio.NewReader(strings.NewReader("3\n2\n")), &out, candidates, filepath.Join(dir, "cliesp.yml"))
+	if err != nil || got != filepath.Join(dir, "work.yaml") {
+		t.Errorf("chooseTarget = %q, %v", got, err)
+	}
+	if !strings.Contains(out.String(), `invalid choice "3"`) {
+		t.Errorf("expected a retry, got %q", out.String())
+	}
+}
+
+func TestRun_AutoTarget(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "base.yml")
+	if err := os.WriteFile(existing, []byte(matchFileHeader), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLIESP_MATCH_DIR", dir)
+	code, _, stderr := runCLI(t, ":a\nA\n\n", "--auto-target")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(existing)
+	if err != nil || !strings.Contains(string(b), `":a"`) {
+		t.Errorf("match should go to the existing file, got %q (err=%v)", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultEspansoMatchFile)); !os.IsNotExist(err) {
+		t.Errorf("default file should not be created, stat err=%v", err)
+	}
+}
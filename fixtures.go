@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fixtureEntry is one match of a generated fixture file.
+type fixtureEntry struct {
+	Triggers []string
+	Replace  string
+	Opts     SnippetOptions
+}
+
+// fixtureFiles are the match files --dump-fixtures writes, one per mode of
+// buildYAMLSnippet, in the order they're written.
+var fixtureFiles = []struct {
+	Name    string
+	Entries []fixtureEntry
+}{
+	{"single.yml", []fixtureEntry{
+		{Triggers: []string{":hi"}, Replace: "Hello!"},
+		{Triggers: []string{":quote"}, Replace: `She said "hi" \ waved`},
+		{Triggers: []string{":dash"}, Replace: "- starts with a dash"},
+		{Triggers: []string{":lbl"}, Replace: "labelled", Opts: SnippetOptions{Label: "Labelled", Word: true}},
+		{Triggers: []string{":hello"}, Replace: "hello there", Opts: SnippetOptions{PropagateCase: true}},
+	}},
+	{"multi-trigger.yml", []fixtureEntry{
+		{Triggers: []string{":sig", ":signature"}, Replace: "Best regards"},
+		{Triggers: []string{":zz", ":aa", ":mm"}, Replace: "sorted triggers", Opts: SnippetOptions{SortTriggers: true}},
+		{Triggers: []string{":email", ":mail"}, Replace: "me@example.com", Opts: SnippetOptions{Extra: []Extra{{Key: "search_terms", Value: "address"}}}},
+	}},
+	{"multiline.yml", []fixtureEntry{
+		{Triggers: []string{":addr"}, Replace: "221B Baker Street\nLondon\nNW1 6XE"},
+		{Triggers: []string{":code"}, Replace: wrapCode("fmt.Println(\"hi\")", "go")},
+		{Triggers: []string{":indented"}, Replace: indentLines("first\n\nsecond", 2)},
+		{Triggers: []string{":long"}, Replace: strings.Repeat("a fairly long sentence that keeps going ", 4) + "end", Opts: SnippetOptions{FoldAt: 40}},
+	}},
+	{"vars.yml", []fixtureEntry{
+		{Triggers: []string{":date"}, Replace: "Today is {{today}}", Opts: SnippetOptions{Vars: []Var{
+			{Name: "today", Type: "date", Params: map[string]string{"format": "%Y-%m-%d"}},
+		}}},
+		{Triggers: []string{":greet"}, Replace: "Hi {{name}},\n\n$|$", Opts: SnippetOptions{Vars: []Var{
+			{Name: "name", Type: "echo", Params: map[string]string{"echo": "there"}},
+		}, DisableInjectVars: true}},
+		{Triggers: []string{":clip"}, Replace: "{{clipboard}}", Opts: SnippetOptions{Vars: []Var{
+			{Name: "clipboard", Type: "clipboard"},
+		}}},
+	}},
+	{"regex.yml", []fixtureEntry{
+		{Triggers: []string{`:greet\((?P<person>.*)\)`}, Replace: "Hi {{person}}!", Opts: SnippetOptions{Regex: true}},
+	}},
+}
+
+// dumpFixtures writes the fixture files into dir, creating it if needed,
+// and returns their paths. Existing files of the same name are replaced.
+func dumpFixtures(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, f := range fixtureFiles {
+		var b strings.Builder
+		b.WriteString(matchFileHeader)
+		for _, e := range f.Entries {
+			b.WriteString(buildYAMLSnippet(e.Triggers, e.Replace, e.Opts))
+		}
+		p := filepath.Join(dir, f.Name)
+		if err := os.WriteFile(p, []byte(b.String()), 0o644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
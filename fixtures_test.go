@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDumpFixtures(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "fixtures")
+	paths, err := dumpFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != len(fixtureFiles) {
+		t.Fatalf("wrote %d files, want %d", len(paths), len(fixtureFiles))
+	}
+	for i, p := range paths {
+		if err := validateMatchFile(p); err != nil {
+			t.Errorf("%s is not valid: %v", p, err)
+			continue
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if issues := lintContent(b); len(issues) > 0 {
+			t.Errorf("%s has lint issues: %v", p, issues)
+		}
+		matches, err := parseMatches(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := len(fixtureFiles[i].Entries); len(matches) != want {
+			t.Errorf("%s has %d matches, want %d", p, len(matches), want)
+		}
+		for j, m := range matches {
+			e := fixtureFiles[i].Entries[j]
+			if e.Opts.Regex {
+				continue
+			}
+			if got := strings.TrimSuffix(m.Replace, "\n"); got != strings.TrimSuffix(e.Replace, "\n") {
+				t.Errorf("%s match %d: replace %q, want %q", p, j, m.Replace, e.Replace)
+			}
+		}
+	}
+}
+
+func TestRun_DumpFixtures(t *testing.T) {
+	dir := t.TempDir()
+	code, stdout, stderr := runCLI(t, "", "--dump-fixtures", dir)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stdout, "Wrote "+filepath.Join(dir, "vars.yml")) {
+		t.Errorf("unexpected stdout: %q", stdout)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// foldLines splits a single-line replacement into lines of at most width
+// characters for a folded (>-) block scalar, which YAML joins back with
+// single spaces. It only breaks at a lone space between two non-space
+// characters, since other breaks wouldn't fold back to the same text; words
+// longer than width get a line of their own. It reports false when s can't
+// be stored folded unchanged: multiline, empty, or with leading or trailing
+// whitespace.
+func foldLines(s string, width int) ([]string, bool) {
+	if width <= 0 || s == "" || strings.ContainsAny(s, "\n\r") || strings.TrimSpace(s) != s {
+		return nil, false
+	}
+	var lines []string
+	start, lastBreak := 0, -1
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] != ' ' || s[i-1] == ' ' || s[i+1] == ' ' {
+			continue
+		}
+		if i-start > width && lastBreak > start {
+			lines = append(lines, s[start:lastBreak])
+			start = lastBreak + 1
+		}
+		lastBreak = i
+	}
+	if len(s)-start > width && lastBreak > start {
+		lines = append(lines, s[start:lastBreak])
+		start = lastBreak + 1
+	}
+	lines = append(lines, s[start:])
+	if !foldsTo(lines, s) {
+		return nil, false
+	}
+	return lines, true
+}
+
+// foldsTo reports whether lines, written as a folded block scalar, parse
+// back to exactly want.
+func foldsTo(lines []string, want string) bool {
+	var doc struct {
+		V string `yaml:"v"`
+	}
+	src := "v: >-\n  " + strings.Join(lines, "\n  ") + "\n"
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		return false
+	}
+	return doc.V == want
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFoldLines(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  []string
+	}{
+		{"short", 20, []string{"short"}},
+		{"the quick brown fox jumps over the lazy dog", 15, []string{"the quick brown", "fox jumps over", "the lazy dog"}},
+		{"a supercalifragilistic word", 5, []string{"a", "supercalifragilistic", "word"}},
+		{"keep  double  spaces together", 8, []string{"keep  double  spaces", "together"}},
+		{"tabs\tstay put here", 6, []string{"tabs\tstay", "put", "here"}},
+	}
+	for _, tt := range tests {
+		got, ok := foldLines(tt.in, tt.width)
+		if !ok {
+			t.Errorf("foldLines(%q, %d) not ok", tt.in, tt.width)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("foldLines(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "two\nlines", " leading", "trailing ", "x"} {
+		if _, ok := foldLines(in, 0); ok && in != "x" {
+			t.Errorf("foldLines(%q, 0) should not fold", in)
+		}
+		if _, ok := foldLines(in, 3); ok && in != "x" {
+			t.Errorf("foldLines(%q, 3) should not fold", in)
+		}
+	}
+}
+
+func TestBuildYAMLSnippet_FoldAtRoundTrip(t *testing.T) {
+	long := "Thanks for reaching out! I'm away until Monday: for anything urgent, ping #ops or email ops@example.com — 100% of the time."
+	snippet := buildYAMLSnippet([]string{":ooo"}, long, SnippetOptions{FoldAt: 30})
+	if !strings.Contains(snippet, "    replace: >-\n") {
+		t.Fatalf("expected a folded scalar:\n%s", snippet)
+	}
+	for _, line := range strings.Split(strings.Trim(snippet, "\n"), "\n") {
+		if content := strings.TrimPrefix(line, "      "); content != line && len(content) > 30 && strings.Contains(content, " ") {
+			t.Errorf("line longer than 30: %q", content)
+		}
+	}
+	matches, err := parseMatches([]byte("matches:" + snippet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Replace != long {
+		t.Errorf("round trip changed the replacement: %q", matches[0].Replace)
+	}
+}
+
+func TestBuildYAMLSnippet_FoldAtFallsBack(t *testing.T) {
+	multi := buildYAMLSnippet([]string{":a"}, "one two\nthree four", SnippetOptions{FoldAt: 3})
+	if want := buildYAMLSnippet([]string{":a"}, "one two\nthree four", SnippetOptions{}); multi != want {
+		t.Errorf("multiline replacement should not be folded:\n%s", multi)
+	}
+	short := buildYAMLSnippet([]string{":a"}, "short", SnippetOptions{FoldAt: 40})
+	if strings.Contains(short, ">-") {
+		t.Errorf("short replacement should not be folded:\n%s", short)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// formatSnippet pipes snippet through command (split like a shell would)
+// with runCommand and returns its stdout as the new snippet. The result must
+// still parse as a match entry, so a broken formatter can't corrupt the
+// match file.
+func formatSnippet(command, snippet string) (string, error) {
+	parts, err := splitArgs(command)
+	if err != nil {
+		return "", err
+	}
+	if len(parts) == 0 {
+		return "", errors.New("empty formatter command")
+	}
+	b, err := runCommand(strings.NewReader(snippet), parts[0], parts[1:]...)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", parts[0], err)
+	}
+
+	out := strings.TrimRight(string(b), "\n") + "\n"
+	if !strings.HasPrefix(out, "\n") {
+		out = "\n" + out
+	}
+	matches, err := parseMatches([]byte("matches:" + out))
+	if err != nil {
+		return "", fmt.Errorf("formatter output is not valid YAML: %w", err)
+	}
+	if len(matches) == 0 {
+		return "", errors.New("formatter output contains no match entry")
+	}
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeFormatter swaps runCommand for one that passes its stdin through fn,
+// and returns the commands it was asked to run.
+func fakeFormatter(t *testing.T, fn func(string) (string, error)) *[]string {
+	t.Helper()
+	var calls []string
+	orig := runCommand
+	t.Cleanup(func() { runCommand = orig })
+	runCommand = func(stdin io.Reader, name string, args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(append([]string{name}, args...), " "))
+		in, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, err
+		}
+		out, err := fn(string(in))
+		return []byte(out), err
+	}
+	return &calls
+}
+
+func TestFormatSnippet_TransformsInput(t *testing.T) {
+	calls := fakeFormatter(t, func(s string) (string, error) {
+		return strings.Replace(s, `replace: "hello"`, `replace: "HELLO"`, 1), nil
+	})
+	snippet := buildYAMLSnippet([]string{":h"}, "hello", SnippetOptions{})
+
+	got, err := formatSnippet(`yamlfmt --in "place holder"`, snippet)
+	if err != nil {
+		t.Fatalf("formatSnippet error: %v", err)
+	}
+	if want := "\n  - trigger: \":h\"\n    replace: \"HELLO\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "yamlfmt --in place holder" {
+		t.Errorf("calls = %q", *calls)
+	}
+}
+
+func TestFormatSnippet_RestoresLeadingNewline(t *testing.T) {
+	fakeFormatter(t, func(s string) (string, error) {
+		return strings.TrimLeft(s, "\n"), nil
+	})
+	got, err := formatSnippet("fmt", buildYAMLSnippet([]string{":h"}, "hi", SnippetOptions{}))
+	if err != nil {
+		t.Fatalf("formatSnippet error: %v", err)
+	}
+	if !strings.HasPrefix(got, "\n  - trigger") {
+		t.Errorf("expected a leading newline, got %q", got)
+	}
+}
+
+func TestFormatSnippet_RejectsBadOutput(t *testing.T) {
+	snippet := buildYAMLSnippet([]string{":h"}, "hi", SnippetOptions{})
+	for name, fn := range map[string]func(string) (string, error){
+		"invalid YAML": func(string) (string, error) { return "  - trigger: [unclosed\n", nil },
+		"no match":     func(string) (string, error) { return "# nothing here\n", nil },
+		"failure":      func(string) (string, error) { return "", errors.New("exit status 3: boom") },
+	} {
+		fakeFormatter(t, fn)
+		_, err := formatSnippet("fmt", snippet)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if name == "failure" && !strings.Contains(err.Error(), "boom") {
+			t.Errorf("failure: error %q should include the command's stderr", err)
+		}
+	}
+}
@@ -5,11 +5,9 @@ go 1.23.4
 require (
 	github.com/joho/godotenv v1.5.1
 	github.com/kvnloughead/cliutils v0.0.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/BurntSushi/toml v1.5.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
-)
+require github.com/BurntSushi/toml v1.5.0 // indirect
 
 replace github.com/kvnloughead/cliutils => ../cliutils
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historyLimit caps how many replacements the history keeps.
+const historyLimit = 20
+
+// historyFilePath returns the replacement history file, under
+// $XDG_STATE_HOME/cliesp or ~/.local/state/cliesp.
+func historyFilePath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "cliesp", "history.json"), nil
+}
+
+// loadHistory returns the stored replacements, newest first. A missing
+// history file is empty.
+func loadHistory(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []string
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("reading history %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// appendHistory records s as the newest replacement. An earlier copy of s is
+// moved to the front rather than duplicated, and the oldest entries beyond
+// historyLimit are dropped.
+func appendHistory(path, s string) error {
+	entries, err := loadHistory(path)
+	if err != nil {
+		return err
+	}
+	next := []string{s}
+	for _, e := range entries {
+		if e != s && len(next) < historyLimit {
+			next = append(next, e)
+		}
+	}
+	b, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// parseHistoryChoice maps a 1-based answer to an index into n entries.
+func parseHistoryChoice(s string, n int) (int, error) {
+	s = strings.TrimSpace(s)
+	for i := 0; i < n; i++ {
+		if s == fmt.Sprint(i+1) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid choice %q", s)
+}
+
+// pickFromHistory lists entries as a numbered menu and returns the chosen
+// one, asking again until the answer is valid.
+func pickFromHistory(in *bufio.Reader, out io.Writer, entries []string, width int) (string, error) {
+	if len(entries) == 0 {
+		return "", errors.New("no replacements in history yet")
+	}
+	for {
+		for i, e := range entries {
+			fmt.Fprintf(out, "  %d) %s\n", i+1, truncatePreview(e, width))
+		}
+		ans, err := prompt(in, out, "reuse which replacement? ")
+		if err != nil {
+			return "", err
+		}
+		i, err := parseHistoryChoice(ans, len(entries))
+		if err == nil {
+			return entries[i], nil
+		}
+		fmt.Fprintln(out, err)
+	}
+}
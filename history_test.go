@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppendHistory_CapsAndOrders(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "state", "history.json")
+	for i := 0; i < historyLimit+5; i++ {
+		if err := appendHistory(p, fmt.Sprintf("entry %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := loadHistory(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != historyLimit {
+		t.Fatalf("len = %d, want %d", len(got), historyLimit)
+	}
+	if got[0] != fmt.Sprintf("entry %d", historyLimit+4) || got[len(got)-1] != "entry 5" {
+		t.Errorf("unexpected order: first %q, last %q", got[0], got[len(got)-1])
+	}
+}
+
+func TestAppendHistory_MovesRepeatToFront(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "history.json")
+	for _, s := range []string{"a", "multi\nline", "a"} {
+		if err := appendHistory(p, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := loadHistory(p)
+	if strings.Join(got, "|") != "a|multi\nline" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestLoadHistory_Missing(t *testing.T) {
+	got, err := loadHistory(filepath.Join(t.TempDir(), "none.json"))
+	if err != nil || len(got) != 0 {
+		t.Errorf("got %v, %v; want empty history", got, err)
+	}
+}
+
+func TestPickFromHistory(t *testing.T) {
+	entries := []string{"first", "second\nline"}
+	var out bytes.Buffer
+	got, err := pickFromHistory(bufio.NewReader(strings.NewReader("9\n2\n")), &out, entries, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "second\nline" {
+		t.Errorf("got %q", got)
+	}
+	if !strings.Contains(out.String(), "2) second line") || !strings.Contains(out.String(), `invalid choice "9"`) {
+		t.Errorf("unexpected menu output: %q", out.String())
+	}
+	if _, err := pickFromHistory(bufio.NewReader(strings.NewReader("1\n")), &out, nil, 60); err == nil {
+		t.Error("expected an error for empty history")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// idCommentPrefix marks the comment carrying a match's stable ID.
+const idCommentPrefix = "# cliesp-id: "
+
+// newMatchID returns a random (version 4) UUID.
+func newMatchID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// idFromComment extracts the ID from a match's head comment, which may hold
+// other comment lines as well.
+func idFromComment(comment string) string {
+	for _, line := range strings.Split(comment, "\n") {
+		if id, ok := strings.CutPrefix(strings.TrimSpace(line), idCommentPrefix); ok {
+			return strings.TrimSpace(id)
+		}
+	}
+	return ""
+}
+
+// findMatchByID returns the match tagged with id in the match file at path.
+func findMatchByID(path, id string) (Match, error) {
+	matches, err := parseMatchFile(path)
+	if err != nil {
+		return Match{}, err
+	}
+	for _, m := range matches {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return Match{}, fmt.Errorf("no match with id %q in %s", id, path)
+}
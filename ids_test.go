@@ -0,0 +1,53 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewMatchID(t *testing.T) {
+	a, err := newMatchID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newMatchID()
+	if !uuidPattern.MatchString(a) {
+		t.Errorf("id %q is not a v4 UUID", a)
+	}
+	if a == b {
+		t.Errorf("expected distinct ids, got %q twice", a)
+	}
+}
+
+func TestBuildYAMLSnippetWithID(t *testing.T) {
+	got := buildYAMLSnippet([]string{":sig"}, "Best", SnippetOptions{ID: "abc-123"})
+	want := "\n  # cliesp-id: abc-123\n  - trigger: \":sig\"\n    replace: \"Best\"\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFindMatchByID(t *testing.T) {
+	content := matchFileHeader +
+		buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{}) +
+		"\n  # a note\n  # cliesp-id: id-b\n  - trigger: \":b\"\n    replace: \"B\"\n" +
+		buildYAMLSnippet([]string{":c"}, "C", SnippetOptions{ID: "id-c"})
+	p := writeMatchFixture(t, content)
+
+	m, err := findMatchByID(p, "id-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Triggers[0] != ":b" || m.Replace != "B" {
+		t.Errorf("unexpected match for id-b: %+v", m)
+	}
+	if m, err = findMatchByID(p, "id-c"); err != nil || m.Triggers[0] != ":c" {
+		t.Errorf("id-c: got %+v, err=%v", m, err)
+	}
+	if _, err := findMatchByID(p, "missing"); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected not-found error, got %v", err)
+	}
+}
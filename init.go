@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// initConfigContent is the config file --init writes.
+func initConfigContent(dir, file string) string {
+	return fmt.Sprintf(`# cliesp settings; see https://github.com/kvnloughead/cliesp for all keys.
+match_dir: %q
+match_file: %q
+`, dir, file)
+}
+
+// writeInitConfig writes a config naming dir and file to path, creating its
+// directory. An existing config is left alone and reported as not created.
+func writeInitConfig(path, dir, file string) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(path, []byte(initConfigContent(dir, file)), 0o644)
+}
+
+// askDefault prompts for a value, keeping def on an empty answer or EOF.
+func askDefault(in *bufio.Reader, out io.Writer, question, def string) (string, error) {
+	ans, err := prompt(in, out, fmt.Sprintf("%s [%s]: ", question, def))
+	if errors.Is(err, io.EOF) {
+		fmt.Fprintln(out)
+		return def, nil
+	}
+	if err != nil || ans == "" {
+		return def, err
+	}
+	return ans, nil
+}
+
+// runInit is the --init setup flow: ask for the match dir and file (or take
+// the configured ones with yes), write the config file if there is none,
+// create the match file with its header and print what to do next.
+func runInit(in *bufio.Reader, stdout, stderr io.Writer, cfg AppConfig, yes bool) int {
+	dir := cfg.MatchDir
+	if dir == "" {
+		dir = defaultEspansoMatchDir
+	}
+	file := cfg.MatchFile
+	if file == "" {
+		file = defaultEspansoMatchFile
+	}
+	if !yes {
+		var err error
+		if dir, err = askDefault(in, stdout, "espanso match directory?", dir); err == nil {
+			file, err = askDefault(in, stdout, "match file name?", file)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "error reading answer:", err)
+			return exitError
+		}
+	}
+
+	cfgPath, err := configFilePath()
+	if err != nil {
+		fmt.Fprintln(stderr, "error locating config file:", err)
+		return exitConfig
+	}
+	created, err := writeInitConfig(cfgPath, dir, file)
+	if err != nil {
+		fmt.Fprintln(stderr, "error writing config:", err)
+		return exitWrite
+	}
+	if created {
+		fmt.Fprintf(stdout, "Created %s\n", cfgPath)
+	} else {
+		fmt.Fprintf(stdout, "%s already exists, leaving it unchanged\n", cfgPath)
+	}
+
+	matchPath, err := resolveMatchPath("", AppConfig{MatchDir: dir, MatchFile: file})
+	if err != nil {
+		fmt.Fprintln(stderr, "error resolving match file path:", err)
+		return exitPath
+	}
+	created, err = ensureFileWithHeader(matchPath, true)
+	if err != nil {
+		fmt.Fprintln(stderr, "error preparing file:", err)
+		return exitWrite
+	}
+	if created {
+		fmt.Fprintf(stdout, "Created %s\n", matchPath)
+	} else {
+		fmt.Fprintf(stdout, "%s already exists\n", matchPath)
+	}
+
+	fmt.Fprintln(stdout, "\nNext steps:")
+	fmt.Fprintln(stdout, "  cliesp              add your first match")
+	fmt.Fprintln(stdout, "  cliesp --list       see the matches in", matchPath)
+	fmt.Fprintln(stdout, "  cliesp --help       see every option; more settings go in", cfgPath)
+	return exitOK
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_InitNonInteractive(t *testing.T) {
+	matchDir := filepath.Join(t.TempDir(), "match")
+	t.Setenv("CLIESP_MATCH_DIR", matchDir)
+	t.Setenv("CLIESP_MATCH_FILE", "base.yml")
+	code, stdout, stderr := runCLI(t, "", "--init", "--yes")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+
+	cfgPath := filepath.Join(os.Getenv("HOME"), ".config", "cliesp", "settings.yaml")
+	b, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatalf("config not written: %v", err)
+	}
+	if want := initConfigContent(matchDir, "base.yml"); string(b) != want {
+		t.Errorf("config = %q, want %q", b, want)
+	}
+	matchPath := filepath.Join(matchDir, "base.yml")
+	b, err = os.ReadFile(matchPath)
+	if err != nil {
+		t.Fatalf("match file not created: %v", err)
+	}
+	if string(b) != matchFileHeader {
+		t.Errorf("unexpected match file:\n%s", b)
+	}
+	for _, want := range []string{"Created " + cfgPath, "Created " + matchPath, "Next steps:"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout lacks %q: %q", want, stdout)
+		}
+	}
+}
+
+func TestRun_InitPrompts(t *testing.T) {
+	matchDir := filepath.Join(t.TempDir(), "espanso")
+	code, stdout, stderr := runCLI(t, matchDir+"\n\n", "--init")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stdout, "match file name? ["+defaultEspansoMatchFile+"]: ") {
+		t.Errorf("unexpected prompts: %q", stdout)
+	}
+	if _, err := os.Stat(filepath.Join(matchDir, defaultEspansoMatchFile)); err != nil {
+		t.Errorf("match file not created: %v", err)
+	}
+}
+
+func TestWriteInitConfig_KeepsExisting(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "settings.yaml")
+	if err := os.WriteFile(p, []byte("match_file: mine.yml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	created, err := writeInitConfig(p, "/m", "x.yml")
+	if err != nil || created {
+		t.Fatalf("created=%v err=%v", created, err)
+	}
+	b, _ := os.ReadFile(p)
+	if string(b) != "match_file: mine.yml\n" {
+		t.Errorf("config overwritten: %q", b)
+	}
+}
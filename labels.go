@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// deriveLabel builds a label for a match from its triggers. rule picks how:
+// "words" (default) turns the first trigger into capitalized words, so
+// ":work-standup" becomes "Work Standup"; "trigger" uses the first trigger
+// as is; "triggers" joins them all with commas. An unknown rule is an error.
+func deriveLabel(triggers []string, rule string) (string, error) {
+	if len(triggers) == 0 {
+		return "", nil
+	}
+	switch strings.ToLower(rule) {
+	case "", "words":
+		words := strings.FieldsFunc(triggers[0], func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for i, w := range words {
+			r := []rune(w)
+			r[0] = unicode.ToUpper(r[0])
+			words[i] = string(r)
+		}
+		if len(words) == 0 {
+			return triggers[0], nil
+		}
+		return strings.Join(words, " "), nil
+	case "trigger":
+		return triggers[0], nil
+	case "triggers":
+		return strings.Join(triggers, ", "), nil
+	default:
+		return "", fmt.Errorf("unknown label rule %q (want words, trigger or triggers)", rule)
+	}
+}
+
+// backfillLabels adds a derived `label:` to every match in content that has
+// none, leaving all other lines as written. The label goes right after the
+// entry's first key, as buildYAMLSnippet places it, and above any comments
+// that belong to the next key. It returns the new content and the first
+// trigger of each match that got a label, in file order.
+func backfillLabels(content []byte, rule string) ([]byte, []string, error) {
+	var doc struct {
+		Matches []yaml.Node `yaml:"matches"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, err
+	}
+	lines := strings.Split(string(content), "\n")
+	// Insert from the bottom up so earlier line numbers stay valid.
+	var labelled []string
+	for i := len(doc.Matches) - 1; i >= 0; i-- {
+		n := &doc.Matches[i]
+		// An entry with a single key has nothing to anchor the label to.
+		if n.Kind != yaml.MappingNode || len(n.Content) < 4 {
+			continue
+		}
+		var r rawMatch
+		if err := n.Decode(&r); err != nil {
+			return nil, nil, err
+		}
+		if r.Label != "" {
+			continue
+		}
+		triggers := r.Triggers
+		if r.Trigger != "" {
+			triggers = append([]string{r.Trigger}, triggers...)
+		}
+		label, err := deriveLabel(triggers, rule)
+		if err != nil {
+			return nil, nil, err
+		}
+		if label == "" {
+			continue
+		}
+		indent := strings.Repeat(" ", n.Column-1)
+		first := n.Content[0].Line
+		at := n.Content[2].Line - 1
+		for at > first && isCommentAt(lines[at-1], indent) {
+			at--
+		}
+		line := fmt.Sprintf("%slabel: %q", indent, label)
+		lines = append(lines[:at], append([]string{line}, lines[at:]...)...)
+		labelled = append([]string{triggers[0]}, labelled...)
+	}
+	out := []byte(strings.Join(lines, "\n"))
+	if err := validateMatchYAML(out); err != nil {
+		return nil, nil, fmt.Errorf("result would be invalid: %w", err)
+	}
+	return out, labelled, nil
+}
+
+// isCommentAt reports whether line is a comment indented exactly by indent,
+// as opposed to text inside a block scalar.
+func isCommentAt(line, indent string) bool {
+	rest, ok := strings.CutPrefix(line, indent)
+	return ok && strings.HasPrefix(rest, "#")
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeriveLabel(t *testing.T) {
+	tests := []struct {
+		triggers []string
+		rule     string
+		want     string
+	}{
+		{[]string{":work-standup"}, "", "Work Standup"},
+		{[]string{":sig", ":signature"}, "words", "Sig"},
+		{[]string{";;addr_home"}, "words", "Addr Home"},
+		{[]string{":::"}, "words", ":::"},
+		{[]string{":sig", ":signature"}, "trigger", ":sig"},
+		{[]string{":sig", ":signature"}, "triggers", ":sig, :signature"},
+		{nil, "words", ""},
+	}
+	for _, tt := range tests {
+		got, err := deriveLabel(tt.triggers, tt.rule)
+		if err != nil {
+			t.Fatalf("deriveLabel(%q, %q) error: %v", tt.triggers, tt.rule, err)
+		}
+		if got != tt.want {
+			t.Errorf("deriveLabel(%q, %q) = %q, want %q", tt.triggers, tt.rule, got, tt.want)
+		}
+	}
+	if _, err := deriveLabel([]string{":a"}, "emoji"); err == nil {
+		t.Error("expected an error for an unknown rule")
+	}
+}
+
+func TestBackfillLabels(t *testing.T) {
+	content := matchFileHeader + `
+  # greeting
+  - trigger: ":hi"
+    replace: "Hello"
+
+  - triggers: [":sig", ":signature"]
+    label: "Signature"
+    replace: |
+      Best,
+      Kev
+
+  - replace: |
+      # TODO
+      - one
+    # trigger below
+    trigger: ":todo-list"
+`
+	out, labelled, err := backfillLabels([]byte(content), "words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(labelled, " ") != ":hi :todo-list" {
+		t.Errorf("labelled = %q, want [:hi :todo-list]", labelled)
+	}
+	want := strings.Replace(content, `  - trigger: ":hi"
+`, `  - trigger: ":hi"
+    label: "Hi"
+`, 1)
+	want = strings.Replace(want, `      - one
+`, `      - one
+    label: "Todo List"
+`, 1)
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+
+	matches, err := parseMatches(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, wantLabel := range []string{"Hi", "Signature", "Todo List"} {
+		if matches[i].Label != wantLabel {
+			t.Errorf("match %d label = %q, want %q", i, matches[i].Label, wantLabel)
+		}
+	}
+	if matches[2].Replace != "# TODO\n- one\n" {
+		t.Errorf("replace changed: %q", matches[2].Replace)
+	}
+
+	again, labelled, err := backfillLabels(out, "words")
+	if err != nil || len(labelled) != 0 || string(again) != string(out) {
+		t.Errorf("second run: labelled=%q err=%v", labelled, err)
+	}
+}
+
+func TestRun_BackfillLabels(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+
+		buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})+
+		buildYAMLSnippet([]string{":b"}, "B", SnippetOptions{Label: "Bee"}))
+	before, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, _ := runCLI(t, "n\n", "--matchFile", p, "--backfill-labels", "--label-rule", "trigger")
+	if code == 0 {
+		t.Error("declining should exit non-zero")
+	}
+	if !strings.Contains(stdout, "1 match(es)") {
+		t.Errorf("prompt should say how many labels are added, got %q", stdout)
+	}
+	if got, _ := os.ReadFile(p); string(got) != string(before) {
+		t.Errorf("declined backfill changed the file:\n%s", got)
+	}
+
+	code, stdout, stderr := runCLI(t, "y\n", "--matchFile", p, "--backfill-labels", "--label-rule", "trigger", "--backup", "--no-gitignore")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stdout, "Added 1 label(s)") {
+		t.Errorf("unexpected stdout: %q", stdout)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "label: \":a\"") || !strings.Contains(string(b), "label: \"Bee\"") {
+		t.Errorf("unexpected file:\n%s", b)
+	}
+	backups, err := filepath.Glob(p + ".bak-*")
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected one backup, got %v (err=%v)", backups, err)
+	}
+	if got, _ := os.ReadFile(backups[0]); string(got) != string(before) {
+		t.Errorf("backup should hold the file before the labels were added, got:\n%s", got)
+	}
+	for _, want := range []string{"Summary:\n", "  operation: backfill-labels\n", "  triggers:  :a\n", "  backup:    " + backups[0] + "\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("summary is missing %q: %q", want, stdout)
+		}
+	}
+
+	if code, _, _ := runCLI(t, "", "--matchFile", p, "--backfill-labels", "--label-rule", "nope"); code != exitUsage {
+		t.Errorf("bad rule: exit=%d, want %d", code, exitUsage)
+	}
+}
+
+func TestRun_BackfillLabelsSafeRejectsPathOutsideMatchDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLIESP_MATCH_DIR", filepath.Join(dir, "match"))
+	content := matchFileHeader + buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})
+	outside := filepath.Join(dir, "outside.yml")
+	if err := os.WriteFile(outside, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "", "--safe", "--matchFile", filepath.Join(dir, "match", "..", "outside.yml"), "--backfill-labels")
+	if code != exitPath {
+		t.Fatalf("exit=%d, want %d; stderr=%q", code, exitPath, stderr)
+	}
+	if got, _ := os.ReadFile(outside); string(got) != content {
+		t.Errorf("file outside the match dir changed: %q", got)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// shebangLanguages maps interpreters named on a #! line to fence languages.
+var shebangLanguages = map[string]string{
+	"sh":      "sh",
+	"bash":    "bash",
+	"zsh":     "zsh",
+	"fish":    "fish",
+	"python":  "python",
+	"python3": "python",
+	"node":    "javascript",
+	"deno":    "typescript",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"lua":     "lua",
+}
+
+// languageRules are tried in order after shebangs and JSON; the first
+// language with a matching pattern wins. Rules for languages whose
+// keywords overlap (Rust's `let mut`, JavaScript's `let`) come first.
+var languageRules = []struct {
+	lang     string
+	patterns []*regexp.Regexp
+}{
+	{"html", []*regexp.Regexp{
+		regexp.MustCompile(`(?i)^\s*<(!doctype html|html|head|body|div|p|span|ul|table)\b`),
+	}},
+	{"sql", []*regexp.Regexp{
+		regexp.MustCompile(`(?i)^\s*(select\s.+\sfrom\s|insert\s+into\s|update\s+\w+\s+set\s|delete\s+from\s|create\s+(table|index|view)\s)`),
+	}},
+	{"go", []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^package \w+\s*$`),
+		regexp.MustCompile(`(?m)^func (\(\w+ \*?\w+\) )?\w+\(.*\).*\{\s*$`),
+		regexp.MustCompile(`\bfmt\.\w+\(|\b\w+ := `),
+	}},
+	{"rust", []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^\s*(pub )?fn \w+(<.*>)?\(`),
+		regexp.MustCompile(`\blet mut \w+|\bprintln!\(`),
+	}},
+	{"python", []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^\s*def \w+\(.*\)( -> .+)?:\s*$`),
+		regexp.MustCompile(`(?m)^(from [\w.]+ )?import [\w.]+( as \w+)?(, [\w.]+)*\s*$`),
+		regexp.MustCompile(`(?m)^\s*(if|elif|for|while) .+:\s*$`),
+	}},
+	{"javascript", []*regexp.Regexp{
+		regexp.MustCompile(`\b(const|let|var) \w+ = `),
+		regexp.MustCompile(`\bconsole\.log\(|=> \{?|(?m)^\s*function \w*\(`),
+	}},
+	{"bash", []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^\s*(echo|export|sudo|cd|mkdir|grep|curl) `),
+		regexp.MustCompile(`(?m)^\s*(if \[|for \w+ in .+; do|fi$|done$)`),
+	}},
+}
+
+// detectLanguage guesses the language of a code snippet from its shebang,
+// whether it's JSON, and a few keyword patterns. It returns "" when unsure.
+func detectLanguage(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	if first, _, _ := strings.Cut(s, "\n"); strings.HasPrefix(first, "#!") {
+		fields := strings.Fields(strings.TrimPrefix(first, "#!"))
+		if len(fields) > 0 {
+			interp := filepath.Base(fields[0])
+			if interp == "env" && len(fields) > 1 {
+				interp = fields[1]
+			}
+			if lang, ok := shebangLanguages[interp]; ok {
+				return lang
+			}
+			if strings.HasPrefix(interp, "python") {
+				return "python"
+			}
+		}
+		return ""
+	}
+	if (s[0] == '{' || s[0] == '[') && json.Valid([]byte(s)) {
+		return "json"
+	}
+	for _, rule := range languageRules {
+		for _, re := range rule.patterns {
+			if re.MatchString(s) {
+				return rule.lang
+			}
+		}
+	}
+	return ""
+}
+
+// extLanguages maps file extensions to fence languages where they differ.
+var extLanguages = map[string]string{
+	"py":   "python",
+	"js":   "javascript",
+	"mjs":  "javascript",
+	"ts":   "typescript",
+	"rs":   "rust",
+	"rb":   "ruby",
+	"yml":  "yaml",
+	"htm":  "html",
+	"md":   "markdown",
+	"h":    "c",
+	"hpp":  "cpp",
+	"cc":   "cpp",
+	"kt":   "kotlin",
+	"ps1":  "powershell",
+	"psm1": "powershell",
+}
+
+// languageForHint turns a --lang-hint into a fence language. The hint may be
+// a file name ("main.py"), an extension (".py" or "py") or a language name.
+func languageForHint(hint string) string {
+	hint = strings.ToLower(strings.TrimSpace(hint))
+	if ext := filepath.Ext(hint); ext != "" {
+		hint = ext
+	}
+	hint = strings.TrimPrefix(hint, ".")
+	if lang, ok := extLanguages[hint]; ok {
+		return lang
+	}
+	return hint
+}
+
+// codeLanguage picks the fence language for --auto-code: the hint when
+// given, otherwise a guess from the snippet itself.
+func codeLanguage(s, hint string) string {
+	if hint != "" {
+		return languageForHint(hint)
+	}
+	return detectLanguage(s)
+}
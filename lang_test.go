@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"shebang env", "#!/usr/bin/env python3\nprint('hi')", "python"},
+		{"shebang path", "#!/bin/bash\necho hi", "bash"},
+		{"unknown shebang", "#!/usr/bin/awk -f\n{ print }", ""},
+		{"json", `{"a": [1, 2]}`, "json"},
+		{"go", "package main\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}", "go"},
+		{"go fragment", "x := compute()\nfmt.Println(x)", "go"},
+		{"rust", "fn main() {\n    let mut n = 1;\n}", "rust"},
+		{"python", "def greet(name):\n    return 'hi ' + name", "python"},
+		{"python import", "import os\nos.getcwd()", "python"},
+		{"javascript", "const add = (a, b) => a + b;", "javascript"},
+		{"bash", "export PATH=$HOME/bin:$PATH\necho done", "bash"},
+		{"sql", "SELECT id, name FROM users WHERE id = 1;", "sql"},
+		{"html", "<div class=\"x\">hi</div>", "html"},
+		{"prose", "Best regards,\nKev", ""},
+		{"empty", "  ", ""},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.in); got != tt.want {
+			t.Errorf("%s: detectLanguage(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageForHint(t *testing.T) {
+	tests := map[string]string{
+		"main.py":  "python",
+		".rs":      "rust",
+		"ts":       "typescript",
+		"Makefile": "makefile",
+		"go":       "go",
+		"x.tar.gz": "gz",
+	}
+	for in, want := range tests {
+		if got := languageForHint(in); got != want {
+			t.Errorf("languageForHint(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRun_AutoCode(t *testing.T) {
+	tests := []struct {
+		args  []string
+		fence string
+	}{
+		{[]string{"--auto-code"}, "```python"},
+		{[]string{"--auto-code", "--lang-hint", "snippet.rb"}, "```ruby"},
+		{[]string{"--auto-code", "--code=text"}, "```text"},
+	}
+	for _, tt := range tests {
+		p := writeMatchFixture(t, matchFileHeader)
+		args := append([]string{"--matchFile", p, "-t", ":py", "--replace", "def f(x):\n    return x"}, tt.args...)
+		code, _, stderr := runCLI(t, "", args...)
+		if code != 0 {
+			t.Fatalf("%v: exit=%d stderr=%q", tt.args, code, stderr)
+		}
+		matches, err := parseMatchFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(matches[0].Replace, tt.fence+"\n") {
+			t.Errorf("%v: replace = %q, want fence %q", tt.args, matches[0].Replace, tt.fence)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LintIssue is a single problem found in a match file. Line is 1-based, or 0
+// when the issue applies to the whole file.
+type LintIssue struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	if i.Line == 0 {
+		return i.Message
+	}
+	return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+}
+
+// lintContent runs every lint check over the content of a match file.
+func lintContent(content []byte) []LintIssue {
+	var issues []LintIssue
+	for _, n := range findTabIndentedLines(content) {
+		issues = append(issues, LintIssue{Line: n, Message: "indented with a tab; espanso YAML requires spaces"})
+	}
+	if err := validateMatchYAML(content); err != nil {
+		return append(issues, LintIssue{Message: "invalid YAML: " + err.Error()})
+	}
+	matches, err := parseMatches(content)
+	if err != nil {
+		return append(issues, LintIssue{Message: "invalid match entries: " + err.Error()})
+	}
+	dups := duplicateTriggers(matches)
+	for _, t := range sortedKeys(dups) {
+		lines := dups[t]
+		for _, n := range lines[1:] {
+			issues = append(issues, LintIssue{Line: n, Message: fmt.Sprintf("duplicate trigger %q (first defined on line %d)", t, lines[0])})
+		}
+	}
+	if colon, bare := triggerPrefixStyles(matches); colon > 0 && bare > 0 {
+		issues = append(issues, LintIssue{Message: fmt.Sprintf("mixes %d trigger(s) starting with ':' and %d without; consider using one style (see trigger_prefix)", colon, bare)})
+	}
+	return issues
+}
+
+// triggerPrefixStyles counts the triggers that start with a colon and those
+// that don't.
+func triggerPrefixStyles(matches []Match) (colon, bare int) {
+	for _, m := range matches {
+		for _, t := range m.Triggers {
+			if strings.HasPrefix(t, ":") {
+				colon++
+			} else {
+				bare++
+			}
+		}
+	}
+	return colon, bare
+}
+
+// duplicateTriggers maps each trigger defined more than once to the lines of
+// the entries that define it.
+func duplicateTriggers(matches []Match) map[string][]int {
+	seen := make(map[string][]int)
+	for _, m := range matches {
+		for _, t := range m.AllTriggers() {
+			seen[t] = append(seen[t], m.Line)
+		}
+	}
+	dups := make(map[string][]int)
+	for t, lines := range seen {
+		if len(lines) > 1 {
+			dups[t] = lines
+		}
+	}
+	return dups
+}
+
+// findDuplicateTriggers parses the match file at path and returns each
+// trigger defined more than once, mapped to the lines of its entries. The
+// `trigger` and `triggers` forms and `regex` patterns are all considered.
+func findDuplicateTriggers(path string) (map[string][]int, error) {
+	matches, err := parseMatchFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return duplicateTriggers(matches), nil
+}
+
+// emptyMatches returns the matches whose replacement is empty or
+// whitespace-only, using the same test as the append-time guard.
+func emptyMatches(matches []Match) []Match {
+	var empty []Match
+	for _, m := range matches {
+		if isBlankReplace(m.Replace) {
+			empty = append(empty, m)
+		}
+	}
+	return empty
+}
+
+// printEmptyMatches writes one line per match with an empty replacement,
+// giving its line and triggers.
+func printEmptyMatches(w io.Writer, matches []Match) {
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "no empty matches found")
+		return
+	}
+	for _, m := range matches {
+		fmt.Fprintf(w, "line %d: %s\n", m.Line, strings.Join(m.AllTriggers(), ", "))
+	}
+}
+
+// printDuplicates writes one line per duplicated trigger with its count and
+// line numbers.
+func printDuplicates(w io.Writer, dups map[string][]int) {
+	if len(dups) == 0 {
+		fmt.Fprintln(w, "no duplicate triggers found")
+		return
+	}
+	for _, t := range sortedKeys(dups) {
+		lines := make([]string, len(dups[t]))
+		for i, n := range dups[t] {
+			lines[i] = strconv.Itoa(n)
+		}
+		fmt.Fprintf(w, "%s  %dx  (lines %s)\n", t, len(dups[t]), strings.Join(lines, ", "))
+	}
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// findTabIndentedLines returns the 1-based numbers of lines whose leading
+// whitespace contains a tab.
+func findTabIndentedLines(content []byte) []int {
+	var lines []int
+	for i, line := range bytes.Split(content, []byte("\n")) {
+		indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+		if bytes.IndexByte(indent, '\t') >= 0 {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// printLintIssues writes one issue per line, prefixed with path.
+func printLintIssues(w io.Writer, path string, issues []LintIssue) {
+	if len(issues) == 0 {
+		fmt.Fprintf(w, "%s: no issues found\n", path)
+		return
+	}
+	for _, i := range issues {
+		fmt.Fprintf(w, "%s: %s\n", path, i)
+	}
+}
+
+// checkReport is the machine-readable output of --check-only --json-errors.
+type checkReport struct {
+	File   string      `json:"file"`
+	Issues []LintIssue `json:"issues"`
+}
+
+// runCheck lints the match file at path without modifying it and returns the
+// exit code: exitOK when clean, exitValidation when issues were found and
+// exitError when the file could not be read. Issues are written to stdout, as
+// JSON when jsonOut is set.
+func runCheck(stdout, stderr io.Writer, path string, jsonOut bool) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if jsonOut {
+			writeCheckReport(stdout, checkReport{File: path, Issues: []LintIssue{{Message: err.Error()}}})
+		} else {
+			fmt.Fprintln(stderr, "error reading match file:", err)
+		}
+		return exitError
+	}
+	issues := lintContent(content)
+	if jsonOut {
+		writeCheckReport(stdout, checkReport{File: path, Issues: issues})
+	} else if len(issues) > 0 {
+		printLintIssues(stdout, path, issues)
+	}
+	if len(issues) > 0 {
+		return exitValidation
+	}
+	return exitOK
+}
+
+// writeCheckReport encodes r as a single line of JSON.
+func writeCheckReport(w io.Writer, r checkReport) {
+	if r.Issues == nil {
+		r.Issues = []LintIssue{}
+	}
+	_ = json.NewEncoder(w).Encode(r)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindTabIndentedLines(t *testing.T) {
+	content := []byte("matches:\n  - trigger: \":a\"\n\treplace: \"A\"\n  - trigger: \":b\"\n  \treplace: \"B\"\n")
+	got := findTabIndentedLines(content)
+	want := []int{3, 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestFindTabIndentedLines_Clean(t *testing.T) {
+	content := []byte("matches:\n  - trigger: \":a\"\n    replace: \"tab\\tinside\"\n")
+	if got := findTabIndentedLines(content); len(got) != 0 {
+		t.Errorf("expected no tab-indented lines, got %v", got)
+	}
+}
+
+func TestLintContent(t *testing.T) {
+	clean := []byte("matches:\n  - trigger: \":a\"\n    replace: \"A\"\n")
+	if issues := lintContent(clean); len(issues) != 0 {
+		t.Errorf("expected clean content, got %v", issues)
+	}
+	dirty := []byte("matches:\n  - trigger: \":a\"\n\treplace: \"A\"\n")
+	if issues := lintContent(dirty); len(issues) == 0 {
+		t.Error("expected issues for tab-indented content")
+	}
+}
+
+func TestLintContent_Duplicates(t *testing.T) {
+	content := []byte("matches:\n  - trigger: \":a\"\n    replace: \"A\"\n  - triggers: [\":b\", \":a\"]\n    replace: \"B\"\n")
+	issues := lintContent(content)
+	if len(issues) != 1 || issues[0].Line != 4 || !strings.Contains(issues[0].Message, `":a"`) {
+		t.Errorf("expected one duplicate issue on line 4, got %v", issues)
+	}
+}
+
+func TestLintContent_MixedPrefixes(t *testing.T) {
+	consistent := []byte("matches:\n  - trigger: \":a\"\n    replace: \"A\"\n  - triggers: [\":b\", \":c\"]\n    replace: \"B\"\n")
+	if issues := lintContent(consistent); len(issues) != 0 {
+		t.Errorf("expected no issues, got %v", issues)
+	}
+	bare := []byte("matches:\n  - trigger: \"a;\"\n    replace: \"A\"\n  - trigger: \"b;\"\n    replace: \"B\"\n")
+	if issues := lintContent(bare); len(issues) != 0 {
+		t.Errorf("expected no issues for bare triggers only, got %v", issues)
+	}
+
+	mixed := []byte("matches:\n  - trigger: \":a\"\n    replace: \"A\"\n  - triggers: [\"b\", \":c\", \"d\"]\n    replace: \"B\"\n")
+	issues := lintContent(mixed)
+	if len(issues) != 1 || issues[0].Line != 0 {
+		t.Fatalf("expected one file-level issue, got %v", issues)
+	}
+	if want := "mixes 2 trigger(s) starting with ':' and 2 without"; !strings.Contains(issues[0].Message, want) {
+		t.Errorf("got %q, want it to contain %q", issues[0].Message, want)
+	}
+}
+
+func TestRunCheck_ExitCodes(t *testing.T) {
+	clean := writeMatchFixture(t, "matches:\n  - trigger: \":a\"\n    replace: \"A\"\n")
+	dirty := writeMatchFixture(t, "matches:\n  - trigger: \":a\"\n    replace: \"A\"\n  - trigger: \":a\"\n    replace: \"again\"\n")
+
+	var out, errOut bytes.Buffer
+	if code := runCheck(&out, &errOut, clean, false); code != 0 {
+		t.Errorf("clean file: exit=%d out=%q", code, out.String())
+	}
+	if out.Len() != 0 {
+		t.Errorf("clean file should print nothing, got %q", out.String())
+	}
+
+	out.Reset()
+	if code := runCheck(&out, &errOut, dirty, false); code == 0 {
+		t.Error("dirty file should exit non-zero")
+	}
+	if !strings.Contains(out.String(), "duplicate trigger") {
+		t.Errorf("expected duplicate finding, got %q", out.String())
+	}
+}
+
+func TestRunCheck_JSON(t *testing.T) {
+	dirty := writeMatchFixture(t, "matches:\n  - trigger: \":a\"\n\treplace: \"A\"\n")
+	var out, errOut bytes.Buffer
+	if code := runCheck(&out, &errOut, dirty, true); code == 0 {
+		t.Fatal("expected non-zero exit")
+	}
+	var r checkReport
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatalf("output is not JSON: %v (%q)", err, out.String())
+	}
+	if r.File != dirty || len(r.Issues) == 0 || r.Issues[0].Line != 3 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	clean := writeMatchFixture(t, "matches:\n")
+	out.Reset()
+	if code := runCheck(&out, &errOut, clean, true); code != 0 {
+		t.Fatalf("clean file: exit=%d", code)
+	}
+	if strings.TrimSpace(out.String()) != `{"file":"`+clean+`","issues":[]}` {
+		t.Errorf("unexpected clean JSON: %q", out.String())
+	}
+}
+
+func TestFindDuplicateTriggers(t *testing.T) {
+	content := "matches:\n" +
+		"  - trigger: \":a\"\n    replace: \"A\"\n" +
+		"  - triggers: [\":b\", \":a\"]\n    replace: \"B\"\n" +
+		"  - trigger: \":b\"\n    replace: \"B2\"\n" +
+		"  - trigger: \":c\"\n    replace: \"C\"\n"
+	p := writeMatchFixture(t, content)
+
+	dups, err := findDuplicateTriggers(p)
+	if err != nil {
+		t.Fatalf("findDuplicateTriggers error: %v", err)
+	}
+	want := map[string][]int{":a": {2, 4}, ":b": {4, 6}}
+	if !reflect.DeepEqual(dups, want) {
+		t.Errorf("got %v want %v", dups, want)
+	}
+
+	var buf bytes.Buffer
+	printDuplicates(&buf, dups)
+	if got := buf.String(); got != ":a  2x  (lines 2, 4)\n:b  2x  (lines 4, 6)\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestEmptyMatches(t *testing.T) {
+	content := "matches:\n" +
+		"  - trigger: \":a\"\n    replace: \"A\"\n" +
+		"  - triggers: [\":b\", \":bb\"]\n    replace: \"\"\n" +
+		"  - trigger: \":c\"\n    replace: \"  \\t \"\n" +
+		"  - trigger: \":d\"\n    replace: |\n      D\n"
+	p := writeMatchFixture(t, content)
+	matches, err := parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	printEmptyMatches(&buf, emptyMatches(matches))
+	if got, want := buf.String(), "line 4: :b, :bb\nline 6: :c\n"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	buf.Reset()
+	printEmptyMatches(&buf, emptyMatches(matches[:1]))
+	if got := buf.String(); got != "no empty matches found\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestFindDuplicateTriggers_None(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	dups, err := findDuplicateTriggers(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dups) != 0 {
+		t.Errorf("expected no duplicates, got %v", dups)
+	}
+}
@@ -5,11 +5,14 @@
 //   - Appends a match entry to a target espanso match file
 //
 // Configuration (in order of precedence):
-//  1. CLI flags: -m | --matchFile (directory or full path)
+//  1. CLI flags: -m | --matchFile (directory or full path), then
+//     -P | --profile (a file named in the config's profiles map)
 //  2. Environment variables / .env files (prefix: CLIESP_)
 //     - CLIESP_MATCH_DIR, CLIESP_MATCH_FILE
 //  3. Config file: ~/.config/cliesp/settings.{yaml|yml|toml|json}
+//     ($XDG_CONFIG_HOME/cliesp when XDG_CONFIG_HOME is set)
 //     - keys: match_dir, match_file
+//     - with CLIESP_ENV=<env>, settings.<env>.yaml is layered on top
 //  4. Defaults:
 //     - dir:  ~/Library/Application Support/espanso/match
 //     - file: cliesp.yml
@@ -24,11 +27,18 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/joho/godotenv"
 	cfgpkg "github.com/kvnloughead/cliutils/config"
@@ -58,8 +68,203 @@ type AppConfig struct {
 	DirOpener  string `json:"dir_opener" yaml:"dir_opener" toml:"dir_opener" env:"DIR_OPENER"`
 	// Multiline input mode: "messaging" (Shift+Enter for newline, Enter submits) or "eof" (EOF/Ctrl+D to submit)
 	MultilineMode string `json:"multiline_mode" yaml:"multiline_mode" toml:"multiline_mode" env:"MULTILINE_MODE"`
+	// Maximum trigger length in characters. Zero disables the check.
+	MaxTriggerLength int `json:"max_trigger_length" yaml:"max_trigger_length" toml:"max_trigger_length" env:"MAX_TRIGGER_LENGTH"`
+	// Routes maps trigger prefixes to match files, e.g. ":work-" -> "work.yml".
+	// Relative files are placed in MatchDir.
+	Routes map[string]string `json:"routes" yaml:"routes" toml:"routes"`
+	// Profiles maps names to match files for --profile, e.g. "work" ->
+	// "work.yml". Targets are resolved like Routes.
+	Profiles map[string]string `json:"profiles" yaml:"profiles" toml:"profiles"`
+	// EnsureFinalNewline trims blank lines at EOF after writing so the file
+	// always ends with exactly one newline.
+	EnsureFinalNewline bool `json:"ensure_final_newline" yaml:"ensure_final_newline" toml:"ensure_final_newline" env:"ENSURE_FINAL_NEWLINE"`
+	// Interactive shows the menu when cliesp runs without arguments, as if
+	// --menu was passed.
+	Interactive bool `json:"interactive" yaml:"interactive" toml:"interactive" env:"INTERACTIVE"`
+	// DefaultFlags are prepended to the command line, e.g. "--yes --dedent".
+	// Quoting follows shell rules; flags given on the command line win.
+	DefaultFlags string `json:"default_flags" yaml:"default_flags" toml:"default_flags" env:"DEFAULT_FLAGS"`
+	// Backup copies the match file to a timestamped .bak-* file before writing.
+	Backup bool `json:"backup" yaml:"backup" toml:"backup" env:"BACKUP"`
+	// BackupDir collects backups in one place instead of next to each file.
+	BackupDir string `json:"backup_dir" yaml:"backup_dir" toml:"backup_dir" env:"BACKUP_DIR"`
+	// BackupKeep is how many backups of each file to keep; older ones are
+	// pruned after each backup. 0 keeps them all.
+	BackupKeep int `json:"backup_keep" yaml:"backup_keep" toml:"backup_keep" env:"BACKUP_KEEP"`
+	// PreviewWidth caps how many characters of a replacement listings show.
+	// Zero uses $COLUMNS when set, else 60.
+	PreviewWidth int `json:"preview_width" yaml:"preview_width" toml:"preview_width" env:"PREVIEW_WIDTH"`
+	// DefaultDateFormat is the strftime format for date vars added without an
+	// explicit one, e.g. "%d/%m/%Y". Empty means "%Y-%m-%d".
+	DefaultDateFormat string `json:"default_date_format" yaml:"default_date_format" toml:"default_date_format" env:"DEFAULT_DATE_FORMAT"`
+	// FormatterCommand, when set, receives each new snippet on stdin; its
+	// stdout replaces the snippet before it's appended.
+	FormatterCommand string `json:"formatter_command" yaml:"formatter_command" toml:"formatter_command" env:"FORMATTER_COMMAND"`
+	// SnippetLibrary is a YAML file mapping keys to reusable replacement text,
+	// read by --snip.
+	SnippetLibrary string `json:"snippet_library" yaml:"snippet_library" toml:"snippet_library" env:"SNIPPET_LIBRARY"`
+	// AppendSorted inserts new matches at their sorted position by trigger
+	// instead of at the end of the file.
+	AppendSorted bool `json:"append_sorted" yaml:"append_sorted" toml:"append_sorted" env:"APPEND_SORTED"`
+	// TemplateEngine selects the placeholder syntax for cliesp-time values
+	// (--define and --template-file): "mustache" ({{key}}, default) or
+	// "dollar" (${key}), which can't collide with espanso's {{var}} tokens.
+	TemplateEngine string `json:"template_engine" yaml:"template_engine" toml:"template_engine" env:"TEMPLATE_ENGINE"`
+	// Safe refuses to write any match file outside MatchDir.
+	Safe bool `json:"safe" yaml:"safe" toml:"safe" env:"SAFE"`
+	// TriggerCase normalizes the case of new triggers: "none" (default),
+	// "lower" or "upper".
+	TriggerCase string `json:"trigger_case" yaml:"trigger_case" toml:"trigger_case" env:"TRIGGER_CASE"`
+	// TriggerPrefix is prepended to new triggers that don't already start
+	// with it, e.g. ":". Empty disables it.
+	TriggerPrefix string `json:"trigger_prefix" yaml:"trigger_prefix" toml:"trigger_prefix" env:"TRIGGER_PREFIX"`
+	// LabelRule is how --backfill-labels derives a label from the triggers:
+	// "words" (default), "trigger" or "triggers".
+	LabelRule string `json:"label_rule" yaml:"label_rule" toml:"label_rule" env:"LABEL_RULE"`
+	// RequireConfirm asks before any command that modifies or removes
+	// existing matches. --yes skips the question either way.
+	RequireConfirm bool `json:"require_confirm" yaml:"require_confirm" toml:"require_confirm" env:"REQUIRE_CONFIRM"`
+	// CreateMissingDirs lets cliesp create the match file's directory. When
+	// false, a missing directory is an error, so a mistyped path can't start
+	// a new tree.
+	CreateMissingDirs bool `json:"create_missing_dirs" yaml:"create_missing_dirs" toml:"create_missing_dirs" env:"CREATE_MISSING_DIRS"`
+	// Reload runs ReloadCommand after every successful append, as --reload
+	// does.
+	Reload bool `json:"reload" yaml:"reload" toml:"reload" env:"RELOAD"`
+	// ReloadCommand makes espanso pick up changes; "none" disables reloading
+	// even with --reload.
+	ReloadCommand string `json:"reload_command" yaml:"reload_command" toml:"reload_command" env:"RELOAD_COMMAND"`
+	// ConfirmAppend shows each new match and asks before appending it.
+	ConfirmAppend bool `json:"confirm_append" yaml:"confirm_append" toml:"confirm_append" env:"CONFIRM_APPEND"`
 }
 
+// cliFlags holds the values of the command line flags.
+type cliFlags struct {
+	matchPath        string
+	profile          string
+	open             bool
+	openDir          bool
+	edit             string
+	maxTriggerLength int
+	allowEmpty       bool
+	allowInvalidUTF8 bool
+	previewFile      bool
+	injectVars       bool
+	usageLog         string
+	dedent           bool
+	straightenQuotes bool
+	yes              bool
+	extends          string
+	listRecent       bool
+	code             optionalFlag
+	autoCode         bool
+	langHint         string
+	lint             bool
+	templateFile     string
+	checkOnly        bool
+	jsonErrors       bool
+	rawTrigger       bool
+	listDuplicates   bool
+	listEmpty        bool
+	completion       string
+	backfillLabels   bool
+	labelRule        string
+	menu             bool
+	printYAMLFor     string
+	numberedInput    bool
+	replaceFromURL   string
+	replaceFromCmd   string
+	editorTemplate   string
+	backup           bool
+	backupDir        string
+	noGitignore      bool
+	showPlaceholders bool
+	triggerCase      string
+	withID           bool
+	byID             bool
+	includeFile      string
+	inline           bool
+	askMode          bool
+	previewWidth     int
+	fromHistory      bool
+	sortTriggers     bool
+	timestamp        optionalFlag
+	force            bool
+	transform        string
+	group            string
+	reload           bool
+	indent           int
+	foldAt           int
+	safe             bool
+	validateDir      bool
+	extra            stringsFlag
+	vars             stringsFlag
+	render           bool
+	snip             string
+	clipboard        bool
+	touch            bool
+	init             bool
+	appendSorted     bool
+	engine           string
+	define           stringsFlag
+	listByFile       bool
+	list             bool
+	search           string
+	count            bool
+	groupByFile      bool
+	trigger          stringsFlag
+	replace          string
+	replaceSet       bool
+	prefix           string
+	prefixSet        bool
+	line             stringsFlag
+	delete           string
+	autoTarget       bool
+	propagateCase    bool
+	word             bool
+	label            string
+	dryRun           bool
+	showContext      bool
+	template         string
+	strict           bool
+	noValidate       bool
+	regex            bool
+	quiet            bool
+	configSchema     bool
+	dumpFixtures     string
+	outputOnly       bool
+	emitTo           string
+}
+
+// optionalFlag is a boolean-style flag that also accepts a value, so both
+// `--code` and `--code=go` work.
+type optionalFlag struct {
+	enabled bool
+	value   string
+}
+
+func (c *optionalFlag) String() string {
+	if c == nil || !c.enabled {
+		return ""
+	}
+	return c.value
+}
+
+func (c *optionalFlag) Set(v string) error {
+	switch v {
+	case "true":
+		c.enabled, c.value = true, ""
+	case "false":
+		c.enabled, c.value = false, ""
+	default:
+		c.enabled, c.value = true, v
+	}
+	return nil
+}
+
+func (c *optionalFlag) IsBoolFlag() bool { return true }
+
 func expandHome(path string) (string, error) {
 	if path == "~" {
 		home, err := os.UserHomeDir()
@@ -78,21 +283,9 @@ func expandHome(path string) (string, error) {
 	return path, nil
 }
 
-// ensureFileWithHeader creates the file (and parent directories) if it does
-// not exist. When creating, it writes a header that includes `matches:` as the
+// matchFileHeader is written to new match files. It ends with the `matches:`
 // root key required by espanso.
-func ensureFileWithHeader(p string) error {
-	// If file doesn't exist, create with header and root matches: key
-	if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
-		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
-			return err
-		}
-		f, err := os.Create(p)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		header := `# espanso match file (managed by cliesp)
+const matchFileHeader = `# espanso match file (managed by cliesp)
 
 # This file is generated and maintained by cliesp. For more information, see https://github.com/kvnloughead/cliesp.
 
@@ -100,70 +293,204 @@ func ensureFileWithHeader(p string) error {
 
 matches:
 `
-		if _, err := f.WriteString(header); err != nil {
-			return err
+
+// ensureFileWithHeader creates the file if it does not exist, along with its
+// parent directories when createDirs is set; otherwise a missing parent is an
+// error. When creating, it writes a header that includes `matches:` as the
+// root key required by espanso. It reports whether the file was created.
+func ensureFileWithHeader(p string, createDirs bool) (bool, error) {
+	// If file doesn't exist, create with header and root matches: key
+	_, err := os.Stat(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if err != nil {
+		dir := filepath.Dir(p)
+		if !createDirs {
+			if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+				return false, fmt.Errorf("directory %s does not exist (set create_missing_dirs: true to create it)", dir)
+			}
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return false, err
+		}
+		f, err := os.Create(p)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		if _, err := f.WriteString(matchFileHeader); err != nil {
+			return true, err
 		}
+		return true, nil
 	}
-	return nil
+	return false, nil
 }
 
-// prompt writes a message to stdout and returns the user's input with trailing
-// newline trimmed.
-func prompt(s string) (string, error) {
-	fmt.Print(s)
-	r := bufio.NewReader(os.Stdin)
-	text, err := r.ReadString('\n')
+// confirmDestructive asks before a command modifies or removes existing
+// content, showing desc as what will change. auto (from --yes, or
+// require_confirm: false) answers yes without asking. EOF counts as "no";
+// other read errors are returned.
+func confirmDestructive(in *bufio.Reader, out io.Writer, desc string, auto bool) (bool, error) {
+	if auto {
+		return true, nil
+	}
+	fmt.Fprintln(out, desc)
+	ans, err := prompt(in, out, "Continue? [y/N] ")
+	if errors.Is(err, io.EOF) {
+		fmt.Fprintln(out)
+		return false, nil
+	}
 	if err != nil {
+		return false, err
+	}
+	ans = strings.ToLower(ans)
+	return ans == "y" || ans == "yes", nil
+}
+
+// prompt writes a message to out and returns the next line from in with
+// surrounding whitespace trimmed.
+func prompt(in *bufio.Reader, out io.Writer, s string) (string, error) {
+	fmt.Fprint(out, s)
+	text, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || text == "") {
 		return "", err
 	}
 	return strings.TrimSpace(text), nil
 }
 
-// promptMultiline writes a message to stdout and reads multiline input.
+// isTerminal reports whether r is an interactive terminal, so optional
+// prompts are only put to a person. Tests swap it to drive those prompts.
+var isTerminal = func(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks a yes/no question and reports whether the answer was y or yes.
+// Any read error, including EOF, counts as "no".
+func confirm(in *bufio.Reader, out io.Writer, question string) bool {
+	ans, err := prompt(in, out, question+" [y/N] ")
+	if err != nil {
+		fmt.Fprintln(out)
+		return false
+	}
+	ans = strings.ToLower(ans)
+	return ans == "y" || ans == "yes"
+}
+
+// readLine returns the next line from in without its line ending. ok is false
+// once in is exhausted.
+func readLine(in *bufio.Reader) (line string, ok bool, err error) {
+	line, err = in.ReadString('\n')
+	if err == io.EOF {
+		if line == "" {
+			return "", false, nil
+		}
+		err = nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimRight(line, "\r\n"), true, nil
+}
+
+// promptMultiline writes a message to out and reads multiline input from in.
 // The behavior depends on the mode:
 // - "messaging": Shift+Enter for newline, Enter submits (like messaging apps)
 // - "eof": Type 'EOF' on a new line or press Ctrl+D to submit (traditional)
-func promptMultiline(s string, mode string) (string, error) {
+//
+// When numbered is set, a line-number prompt such as "  3> " is written to out
+// before each line. It is never part of the returned text.
+func promptMultiline(in *bufio.Reader, out io.Writer, s string, mode string, numbered bool) (string, error) {
 	if mode == multilineModeMessaging {
-		return promptMultilineMessaging(s)
+		return promptMultilineMessaging(in, out, s, numbered)
+	}
+	return promptMultilineEOF(in, out, s, numbered)
+}
+
+// multilineModes lists the multiline input modes offered by --ask-mode.
+var multilineModes = []struct {
+	name, desc string
+}{
+	{multilineModeMessaging, "Enter twice (empty line) to submit; good for short text"},
+	{multilineModeEOF, "type EOF or press Ctrl+D to submit; keeps blank lines, good for code"},
+}
+
+// parseModeChoice maps a menu answer (a number or a mode name) to a
+// multiline mode. An empty answer selects def.
+func parseModeChoice(s, def string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return def, nil
+	}
+	for i, m := range multilineModes {
+		if s == m.name || s == strconv.Itoa(i+1) {
+			return m.name, nil
+		}
+	}
+	return "", fmt.Errorf("invalid multiline mode %q", s)
+}
+
+// askMultilineMode lists the multiline modes and reads the user's choice.
+func askMultilineMode(in *bufio.Reader, out io.Writer, def string) (string, error) {
+	for i, m := range multilineModes {
+		fmt.Fprintf(out, "  %d) %-9s %s\n", i+1, m.name, m.desc)
+	}
+	answer, err := prompt(in, out, fmt.Sprintf("multiline mode? [%s]: ", def))
+	if err != nil {
+		return "", err
+	}
+	return parseModeChoice(answer, def)
+}
+
+// promptLineNumber writes the "  n> " prompt used by numbered input.
+func promptLineNumber(out io.Writer, n int, numbered bool) {
+	if numbered {
+		fmt.Fprintf(out, "%3d> ", n)
 	}
-	return promptMultilineEOF(s)
 }
 
 // promptMultilineEOF implements the traditional EOF-based multiline input
-func promptMultilineEOF(s string) (string, error) {
-	fmt.Print(s)
-	fmt.Println("(Type 'EOF' on a new line when finished, or press Ctrl+D)")
+func promptMultilineEOF(in *bufio.Reader, out io.Writer, s string, numbered bool) (string, error) {
+	fmt.Fprint(out, s)
+	fmt.Fprintln(out, "(Type 'EOF' on a new line when finished, or press Ctrl+D)")
 
-	scanner := bufio.NewScanner(os.Stdin)
 	var lines []string
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "EOF" {
+	for {
+		promptLineNumber(out, len(lines)+1, numbered)
+		line, ok, err := readLine(in)
+		if err != nil {
+			return "", err
+		}
+		if !ok || line == "EOF" {
 			break
 		}
 		lines = append(lines, line)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return "", err
-	}
-
 	return strings.Join(lines, "\n"), nil
 }
 
 // promptMultilineMessaging implements messaging app style input:
 // Double Enter (empty line) submits, single Enter creates newline
-func promptMultilineMessaging(s string) (string, error) {
-	fmt.Print(s)
-	fmt.Println("(Press Enter twice (empty line) to submit, single Enter for new line)")
+func promptMultilineMessaging(in *bufio.Reader, out io.Writer, s string, numbered bool) (string, error) {
+	fmt.Fprint(out, s)
+	fmt.Fprintln(out, "(Press Enter twice (empty line) to submit, single Enter for new line)")
 
-	scanner := bufio.NewScanner(os.Stdin)
 	var lines []string
-
-	for scanner.Scan() {
-		line := scanner.Text()
+	for {
+		promptLineNumber(out, len(lines)+1, numbered)
+		line, ok, err := readLine(in)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			break
+		}
 
 		// Empty line submits (like messaging apps with double-enter)
 		if line == "" && len(lines) > 0 {
@@ -173,21 +500,294 @@ func promptMultilineMessaging(s string) (string, error) {
 		lines = append(lines, line)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return "", err
+	return strings.Join(lines, "\n"), nil
+}
+
+// Var describes an espanso variable rendered in a match's `vars:` block.
+type Var struct {
+	Name   string
+	Type   string
+	Params map[string]string
+}
+
+// SnippetOptions holds the optional parts of a match entry. The zero value
+// produces a plain trigger/replace entry.
+type SnippetOptions struct {
+	Vars []Var
+	// DisableInjectVars emits `inject_vars: false` on each var, stopping
+	// espanso from expanding references to other vars inside its params.
+	DisableInjectVars bool
+	// Extends names a YAML anchor whose keys the match inherits via `<<: *name`.
+	Extends string
+	// Extra holds additional scalar keys, such as word or propagate_case,
+	// emitted after the standard ones in the order given.
+	Extra []Extra
+	// SortTriggers emits the triggers of a multi-trigger entry in sorted
+	// order instead of the order they were given.
+	SortTriggers bool
+	// ID is written as a `# cliesp-id:` comment above the match so tools can
+	// find it even after its trigger changes.
+	ID string
+	// PropagateCase emits `propagate_case: true` so the trigger also matches
+	// its capitalized and uppercase variants.
+	PropagateCase bool
+	// Word emits `word: true` so the trigger only expands as a whole word.
+	Word bool
+	// Label is shown for the match in espanso's search UI. Empty omits it.
+	Label string
+	// Regex emits the single trigger as a `regex:` pattern instead.
+	Regex bool
+	// FoldAt stores a long single-line replacement as a folded (>-) block
+	// scalar wrapped at this many characters. Zero disables folding.
+	FoldAt int
+}
+
+// Extra is an additional `key: value` pair on a match.
+type Extra struct {
+	Key, Value string
+}
+
+// extraKeyPattern limits extra keys to plain YAML identifiers like espanso's.
+var extraKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// reservedMatchKeys are written by cliesp itself and can't be set via --extra.
+var reservedMatchKeys = map[string]bool{"trigger": true, "triggers": true, "replace": true, "vars": true}
+
+// parseExtra parses a `key=value` argument of --extra.
+func parseExtra(s string) (Extra, error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return Extra{}, fmt.Errorf("invalid extra %q, want key=value", s)
 	}
+	if !extraKeyPattern.MatchString(key) {
+		return Extra{}, fmt.Errorf("invalid extra key %q (use lowercase letters, digits and _)", key)
+	}
+	if reservedMatchKeys[key] {
+		return Extra{}, fmt.Errorf("extra key %q is set by cliesp itself", key)
+	}
+	if strings.ContainsAny(value, "\n\r") {
+		return Extra{}, fmt.Errorf("extra %q: value must be a single line", key)
+	}
+	return Extra{Key: key, Value: value}, nil
+}
 
-	return strings.Join(lines, "\n"), nil
+// extraValue renders an extra value: booleans and integers stay bare so
+// espanso sees their type, everything else is quoted.
+func extraValue(v string) string {
+	if v == "true" || v == "false" {
+		return v
+	}
+	if _, err := strconv.Atoi(v); err == nil {
+		return v
+	}
+	return fmt.Sprintf("%q", v)
+}
+
+// stringsFlag collects the values of a repeatable flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// defaultDateFormat is used for date vars when neither the caller nor the
+// DefaultDateFormat config gives a format.
+const defaultDateFormat = "%Y-%m-%d"
+
+// timestampVarName names the date var that --timestamp emits.
+const timestampVarName = "timestamp"
+
+// dateVar returns an espanso date var. An empty format falls back to def
+// (normally the DefaultDateFormat config), then to defaultDateFormat.
+func dateVar(name, format, def string) Var {
+	if format == "" {
+		format = def
+	}
+	if format == "" {
+		format = defaultDateFormat
+	}
+	return Var{Name: name, Type: "date", Params: map[string]string{"format": format}}
+}
+
+// varNamePattern limits --var names to identifiers usable in {{name}}.
+var varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseVarFlag parses a `name=type` argument of --var. Only the date and
+// clipboard types are supported; date vars use dateFormat (normally the
+// DefaultDateFormat config).
+func parseVarFlag(s, dateFormat string) (Var, error) {
+	name, typ, ok := strings.Cut(s, "=")
+	name, typ = strings.TrimSpace(name), strings.TrimSpace(typ)
+	if !ok || name == "" || typ == "" {
+		return Var{}, fmt.Errorf("invalid var %q, want name=type", s)
+	}
+	if !varNamePattern.MatchString(name) {
+		return Var{}, fmt.Errorf("invalid var name %q (use letters, digits and _)", name)
+	}
+	switch typ {
+	case "date":
+		return dateVar(name, "", dateFormat), nil
+	case "clipboard":
+		return Var{Name: name, Type: typ}, nil
+	default:
+		return Var{}, fmt.Errorf("unsupported var type %q (want date or clipboard)", typ)
+	}
+}
+
+// buildVarsBlock renders the `vars:` list for a match, or "" when there are
+// no vars. Params are emitted in sorted key order so output is stable.
+func buildVarsBlock(opts SnippetOptions) string {
+	if len(opts.Vars) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("    vars:\n")
+	for _, v := range opts.Vars {
+		b.WriteString(fmt.Sprintf("      - name: %q\n", v.Name))
+		b.WriteString(fmt.Sprintf("        type: %s\n", v.Type))
+		if len(v.Params) > 0 {
+			keys := make([]string, 0, len(v.Params))
+			for k := range v.Params {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			b.WriteString("        params:\n")
+			for _, k := range keys {
+				b.WriteString(fmt.Sprintf("          %s: %q\n", k, v.Params[k]))
+			}
+		}
+		if opts.DisableInjectVars {
+			b.WriteString("        inject_vars: false\n")
+		}
+	}
+	return b.String()
+}
+
+// parseTriggers splits a line of input into triggers. Triggers are separated
+// by whitespace; a double-quoted trigger keeps its spaces, so `":foo " :bar`
+// yields ":foo " and ":bar".
+func parseTriggers(line string) []string {
+	var triggers []string
+	var cur strings.Builder
+	inQuotes, quoted := false, false
+	flush := func() {
+		if cur.Len() > 0 || quoted {
+			triggers = append(triggers, cur.String())
+		}
+		cur.Reset()
+		quoted = false
+	}
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case !inQuotes && unicode.IsSpace(r):
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return triggers
+}
+
+// transformTriggerCase applies a TriggerCase setting to triggers. An empty
+// mode is treated as "none"; an unknown mode is an error.
+func transformTriggerCase(triggers []string, mode string) ([]string, error) {
+	var fn func(string) string
+	switch strings.ToLower(mode) {
+	case "", "none":
+		return triggers, nil
+	case "lower":
+		fn = strings.ToLower
+	case "upper":
+		fn = strings.ToUpper
+	default:
+		return nil, fmt.Errorf("unknown trigger case %q (want none, lower or upper)", mode)
+	}
+	out := make([]string, len(triggers))
+	for i, t := range triggers {
+		out[i] = fn(t)
+	}
+	return out, nil
+}
+
+// applyTriggerPrefix prepends prefix to each trigger that doesn't already
+// start with it, so ":sig" stays ":sig" rather than becoming "::sig". An
+// empty prefix leaves triggers unchanged.
+func applyTriggerPrefix(triggers []string, prefix string) []string {
+	if prefix == "" {
+		return triggers
+	}
+	out := make([]string, len(triggers))
+	for i, t := range triggers {
+		if !strings.HasPrefix(t, prefix) {
+			t = prefix + t
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// leadingIndent reports whether the first non-blank line of s starts with
+// whitespace.
+func leadingIndent(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			return line[0] == ' ' || line[0] == '\t'
+		}
+	}
+	return false
+}
+
+// yamlKeyPattern matches text that starts like a YAML mapping key.
+var yamlKeyPattern = regexp.MustCompile(`^\s*[\w.-]+\s*:(\s|$)`)
+
+// needsBlockScalar reports whether a single-line replace looks like YAML
+// structure (a key, a list item, a comment, an anchor or other indicator) and
+// is safer emitted as a block scalar. Text with leading spaces is excluded,
+// since a block scalar can't start with extra indentation without an
+// indentation indicator.
+func needsBlockScalar(s string) bool {
+	if strings.Contains(s, "\n") {
+		return true
+	}
+	if s == "" || strings.HasPrefix(s, " ") {
+		return false
+	}
+	if yamlKeyPattern.MatchString(s) {
+		return true
+	}
+	if strings.ContainsRune("-?:#&*!|>%@`", rune(s[0])) {
+		return true
+	}
+	return strings.Contains(s, " #") || strings.Contains(s, ": ")
 }
 
 // buildYAMLSnippet returns a YAML fragment representing an espanso match
 // entry. For a single trigger, the YAML uses `trigger:`; for multiple,
 // it uses an inline list with `triggers:`. Multiline replace strings use
-// the YAML literal block style (|) with proper indentation.
-func buildYAMLSnippet(triggers []string, replace string) string {
+// the YAML literal block style (|) with proper indentation. Optional fields
+// from opts follow the replace.
+func buildYAMLSnippet(triggers []string, replace string, opts SnippetOptions) string {
+	if opts.SortTriggers {
+		triggers = append([]string(nil), triggers...)
+		slices.SortFunc(triggers, triggerCompare)
+	}
 	var b strings.Builder
-	b.WriteString("\n  - ")
-	if len(triggers) == 1 {
+	b.WriteString("\n")
+	if opts.ID != "" {
+		b.WriteString("  " + idCommentPrefix + opts.ID + "\n")
+	}
+	b.WriteString("  - ")
+	if opts.Regex {
+		b.WriteString(fmt.Sprintf("regex: %q\n", triggers[0]))
+	} else if len(triggers) == 1 {
 		b.WriteString("trigger: ")
 		// Quote if contains spaces or special chars; espanso examples show both quoted and unquoted.
 		// We'll quote unless it's a simple :word pattern.
@@ -202,18 +802,51 @@ func buildYAMLSnippet(triggers []string, replace string) string {
 		}
 		b.WriteString("]\n")
 	}
+	if opts.Label != "" {
+		b.WriteString(fmt.Sprintf("    label: %q\n", opts.Label))
+	}
+	if opts.PropagateCase {
+		b.WriteString("    propagate_case: true\n")
+	}
+	if opts.Word {
+		b.WriteString("    word: true\n")
+	}
+	if opts.Extends != "" {
+		b.WriteString("    <<: *" + opts.Extends + "\n")
+	}
 
-	// Handle multiline replace strings with YAML literal block style
-	if strings.Contains(replace, "\n") {
-		b.WriteString("    replace: |\n")
+	// Long single lines may be folded (>-); YAML joins the lines back into
+	// the original line. Multiline replace strings use the literal style.
+	folded, fold := foldLines(replace, opts.FoldAt)
+	if fold && len(folded) > 1 {
+		b.WriteString("    replace: >-\n")
+		for _, line := range folded {
+			b.WriteString("      " + line + "\n")
+		}
+	} else if strings.Contains(replace, "\n") {
+		// When the first non-blank line is indented, YAML would take that as
+		// the block's indentation and drop it, so state it explicitly (|2).
+		header := "    replace: |\n"
+		if leadingIndent(replace) {
+			header = "    replace: |2\n"
+		}
+		b.WriteString(header)
 		// Indent each line with 6 spaces (4 for replace + 2 for literal block content)
 		for _, line := range strings.Split(replace, "\n") {
 			b.WriteString("      " + line + "\n")
 		}
+	} else if needsBlockScalar(replace) {
+		// Strip chomping (|-) keeps the single line free of a trailing newline
+		b.WriteString("    replace: |-\n")
+		b.WriteString("      " + replace + "\n")
 	} else {
 		b.WriteString("    replace: ")
 		b.WriteString(fmt.Sprintf("%q\n", replace))
 	}
+	b.WriteString(buildVarsBlock(opts))
+	for _, e := range opts.Extra {
+		b.WriteString("    " + e.Key + ": " + extraValue(e.Value) + "\n")
+	}
 	return b.String()
 }
 
@@ -267,15 +900,279 @@ func resolveMatchPath(flagPath string, cfg AppConfig) (string, error) {
 	return filepath.Join(dir, file), nil
 }
 
+// routeByPrefix picks the match file for trigger from routes, preferring the
+// longest matching prefix. Route targets are resolved like --matchFile when
+// they are paths, or as a filename inside the configured match dir otherwise.
+// It reports false when no route applies.
+func routeByPrefix(trigger string, routes map[string]string, cfg AppConfig) (string, bool) {
+	best := ""
+	for prefix := range routes {
+		if strings.HasPrefix(trigger, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	p, err := resolveTarget(routes[best], cfg)
+	if err != nil {
+		return "", false
+	}
+	return p, true
+}
+
+// resolveTarget resolves a configured match file target: like --matchFile
+// when it is a path, or as a filename inside the configured match dir
+// otherwise.
+func resolveTarget(target string, cfg AppConfig) (string, error) {
+	if strings.ContainsRune(target, '/') || strings.ContainsRune(target, os.PathSeparator) || strings.HasPrefix(target, "~") {
+		return resolveMatchPath(target, cfg)
+	}
+	cfg.MatchFile = target
+	return resolveMatchPath("", cfg)
+}
+
+// resolveProfile returns the match file configured for the named profile.
+// An unknown name is an error listing the known profiles.
+func resolveProfile(name string, cfg AppConfig) (string, error) {
+	target, ok := cfg.Profiles[name]
+	if !ok {
+		known := sortedKeys(cfg.Profiles)
+		if len(known) == 0 {
+			return "", fmt.Errorf("unknown profile %q (no profiles configured)", name)
+		}
+		return "", fmt.Errorf("unknown profile %q (known profiles: %s)", name, strings.Join(known, ", "))
+	}
+	return resolveTarget(target, cfg)
+}
+
+// splitArgs splits s into arguments like a POSIX shell would, honoring single
+// quotes, double quotes and backslash escapes. No expansion is performed.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// defaultConfig returns the settings used when neither the config file nor
+// the environment sets a value.
+func defaultConfig() AppConfig {
+	return AppConfig{
+		MatchDir:           defaultEspansoMatchDir,
+		MatchFile:          defaultEspansoMatchFile,
+		MultilineMode:      defaultMultilineMode,
+		EnsureFinalNewline: true,
+		RequireConfirm:     true,
+		BackupKeep:         defaultBackupKeep,
+		CreateMissingDirs:  true,
+		ConfirmAppend:      true,
+		ReloadCommand:      defaultReloadCommand,
+	}
+}
+
 // defineFlags wires up flags on the provided FlagSet. It supports a full and
 // shorthand for each relevant option.
-func defineFlags(fs *flag.FlagSet, matchPath *string, openFile *bool, openDir *bool) {
-	fs.StringVar(matchPath, "matchFile", "", "Path to the espanso match file (overrides config). Accepts a directory or full file path.")
-	fs.StringVar(matchPath, "m", "", "Shorthand for --matchFile")
-	fs.BoolVar(openFile, "open", false, "Open the resolved match file and exit")
-	fs.BoolVar(openFile, "o", false, "Shorthand for --open")
-	fs.BoolVar(openDir, "openDir", false, "Open the resolved match directory and exit")
-	fs.BoolVar(openDir, "d", false, "Shorthand for --openDir")
+func defineFlags(fs *flag.FlagSet, f *cliFlags) {
+	fs.StringVar(&f.matchPath, "matchFile", "", "Path to the espanso match file (overrides config). Accepts a directory or full file path.")
+	fs.StringVar(&f.matchPath, "m", "", "Shorthand for --matchFile")
+	fs.StringVar(&f.profile, "profile", "", "Use the match file of this profile from the config's profiles map")
+	fs.StringVar(&f.profile, "P", "", "Shorthand for --profile")
+	fs.BoolVar(&f.open, "open", false, "Open the resolved match file and exit")
+	fs.BoolVar(&f.open, "o", false, "Shorthand for --open")
+	fs.BoolVar(&f.openDir, "openDir", false, "Open the resolved match directory and exit")
+	fs.BoolVar(&f.openDir, "d", false, "Shorthand for --openDir")
+	fs.StringVar(&f.edit, "edit", "", "Open the match file in the editor at the line defining this trigger and exit")
+	fs.BoolVar(&f.noValidate, "no-validate", false, "Skip the check for empty, whitespace-only and control-character triggers")
+	fs.IntVar(&f.maxTriggerLength, "max-trigger-length", 0, "Reject triggers longer than this many characters (0 disables)")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "Print the snippet that would be appended, without writing")
+	fs.BoolVar(&f.dryRun, "n", false, "Shorthand for --dry-run")
+	fs.BoolVar(&f.previewFile, "preview-file", false, "Print the whole file as it would look after the append, without writing")
+	fs.BoolVar(&f.injectVars, "inject-vars", true, "Let vars reference other vars (use --inject-vars=false to emit inject_vars: false)")
+	fs.StringVar(&f.usageLog, "usage", "", "Report how often each trigger fired according to an espanso log file, and list never-used triggers")
+	fs.BoolVar(&f.dedent, "dedent", false, "Strip the leading indentation common to all lines of the replacement")
+	fs.BoolVar(&f.straightenQuotes, "straighten-quotes", false, "Replace smart quotes in the replacement with straight ASCII quotes")
+	fs.Var(&f.trigger, "trigger", "Trigger to add, taken verbatim instead of prompting (repeatable)")
+	fs.Var(&f.trigger, "t", "Shorthand for --trigger")
+	fs.StringVar(&f.replace, "replace", "", "Replacement text instead of prompting; - reads it from stdin until EOF, @path from a file (@@ for a literal @)")
+	fs.StringVar(&f.replace, "r", "", "Shorthand for --replace")
+	fs.Var(&f.line, "line", "One line of the replacement; repeat to build a multiline replacement")
+	fs.BoolVar(&f.autoTarget, "auto-target", false, "When the default match file doesn't exist, use the only other match file in the match dir without asking")
+	fs.BoolVar(&f.propagateCase, "propagate-case", false, "Emit propagate_case: true so the trigger also matches case variations")
+	fs.BoolVar(&f.propagateCase, "p", false, "Shorthand for --propagate-case")
+	fs.BoolVar(&f.word, "word", false, "Emit word: true so the trigger only expands as a whole word")
+	fs.BoolVar(&f.word, "w", false, "Shorthand for --word")
+	fs.StringVar(&f.label, "label", "", "Label shown for the match in espanso's search UI")
+	fs.BoolVar(&f.backfillLabels, "backfill-labels", false, "Add a label derived from the triggers to every match without one and exit")
+	fs.StringVar(&f.labelRule, "label-rule", "", "How --backfill-labels derives labels: words, trigger or triggers (overrides config)")
+	fs.BoolVar(&f.showContext, "show-context", false, "Print the target file, input mode and match file count before prompting")
+	fs.BoolVar(&f.regex, "regex", false, "Emit a regex: pattern (from the first argument or a prompt) instead of a trigger")
+	fs.BoolVar(&f.regex, "x", false, "Shorthand for --regex")
+	fs.BoolVar(&f.quiet, "quiet", false, "Don't print progress messages or the change summary")
+	fs.BoolVar(&f.quiet, "q", false, "Shorthand for --quiet")
+	fs.BoolVar(&f.yes, "yes", false, "Skip confirmation prompts")
+	fs.BoolVar(&f.yes, "y", false, "Shorthand for --yes")
+	fs.StringVar(&f.extends, "extends", "", "Inherit keys from a YAML anchor defined in the match file (emits <<: *anchor)")
+	fs.StringVar(&f.delete, "delete", "", "Remove the match that defines this trigger from the match file and exit")
+	fs.BoolVar(&f.list, "list", false, "Print the triggers and a replace preview of every match in the match file and exit")
+	fs.BoolVar(&f.list, "l", false, "Shorthand for --list")
+	fs.BoolVar(&f.count, "count", false, "Print the number of matches in the match file and exit")
+	fs.StringVar(&f.search, "search", "", "Print the matches whose triggers or replacement contain this text (case-insensitive) and exit")
+	fs.BoolVar(&f.groupByFile, "group-by-file", false, "With --list, list every match file in the match dir grouped by file")
+	fs.BoolVar(&f.listRecent, "list-recent", false, "List match files in the match dir, most recently modified first, and exit")
+	fs.Var(&f.code, "code", "Wrap the replacement in a fenced code block; use --code=LANG to set the language")
+	fs.BoolVar(&f.autoCode, "auto-code", false, "Wrap the replacement in a fenced code block tagged with its guessed language")
+	fs.StringVar(&f.langHint, "lang-hint", "", "With --auto-code, take the language from this file name, extension or language name")
+	fs.BoolVar(&f.lint, "lint", false, "Check the match file for problems (tab indentation, invalid YAML, duplicate triggers) and exit")
+	fs.StringVar(&f.templateFile, "template-file", "", "Build the match from a YAML fragment with {{trigger}} and {{replace}} placeholders")
+	fs.BoolVar(&f.checkOnly, "check-only", false, "Lint the match file without modifying anything; exit non-zero on issues (for CI)")
+	fs.BoolVar(&f.jsonErrors, "json-errors", false, "With --check-only, print issues as JSON")
+	fs.BoolVar(&f.rawTrigger, "raw-trigger", false, "Read a single trigger verbatim, keeping leading/trailing spaces")
+	// Hidden: not listed in usage.
+	fs.StringVar(&f.completion, "completion", "", "Print a completion script for bash, zsh or fish and exit")
+	fs.BoolVar(&f.listEmpty, "list-empty", false, "List matches whose replacement is empty or whitespace-only and exit (non-zero if any)")
+	fs.BoolVar(&f.listDuplicates, "list-duplicates", false, "List triggers defined more than once in the match file and exit")
+	fs.BoolVar(&f.menu, "menu", false, "Show an interactive menu (add, list, open file/dir, edit config)")
+	fs.StringVar(&f.printYAMLFor, "print-yaml-for", "", "Print the YAML of the match with this trigger and exit")
+	fs.BoolVar(&f.numberedInput, "numbered-input", false, "Show a line-number prompt while entering a multiline replacement")
+	fs.StringVar(&f.replaceFromURL, "replace-from-url", "", "Use the body fetched from this URL as the replacement")
+	fs.StringVar(&f.replaceFromCmd, "replace-from-command", "", "Run this command now and use its output as the (static) replacement")
+	fs.BoolVar(&f.backup, "backup", false, "Back up the match file before writing to it")
+	fs.BoolVar(&f.noGitignore, "no-gitignore", false, "Don't add the backup pattern to a .gitignore in the backup dir")
+	fs.StringVar(&f.backupDir, "backup-dir", "", "Directory for backups (default: next to the match file)")
+	fs.StringVar(&f.prefix, "prefix", "", "Prepend this to triggers that don't already start with it, e.g. \":\" (overrides config; \"\" disables)")
+	fs.StringVar(&f.triggerCase, "trigger-case-transform", "", "Change the case of triggers before they're checked and written: none, lower or upper (overrides config)")
+	fs.BoolVar(&f.outputOnly, "output-only", false, "Only print the YAML snippet; never read config or touch any match file")
+	fs.StringVar(&f.emitTo, "emit-to", "", "With --output-only, write the snippet to this file instead of stdout")
+	fs.StringVar(&f.dumpFixtures, "dump-fixtures", "", "Write sample match files covering each snippet style into this directory and exit")
+	fs.BoolVar(&f.configSchema, "config-schema", false, "Print the available config keys with their types, env vars and defaults, and exit")
+	fs.BoolVar(&f.withID, "with-id", false, "Tag the new match with a stable `# cliesp-id:` comment")
+	fs.BoolVar(&f.byID, "id", false, "Treat the --edit or --delete argument as a cliesp-id instead of a trigger")
+	fs.StringVar(&f.includeFile, "include-file", "", "Expand the match to the contents of this file, read by a shell var each time it fires")
+	fs.BoolVar(&f.inline, "inline", false, "With --include-file, copy the file's contents into the match now instead")
+	fs.BoolVar(&f.askMode, "ask-mode", false, "Ask which multiline input mode to use before reading the replacement")
+	fs.IntVar(&f.previewWidth, "preview-width", 0, "Characters of each replacement to show in listings before an ellipsis (overrides config)")
+	fs.StringVar(&f.editorTemplate, "replace-from-editor-template", "", "Open $EDITOR pre-filled with this template file and use the saved text as the replacement")
+	fs.BoolVar(&f.fromHistory, "from-history", false, "Pick the replacement from recently used ones")
+	fs.BoolVar(&f.sortTriggers, "sort-triggers", false, "Write the triggers of a multi-trigger entry in sorted order")
+	fs.Var(&f.timestamp, "timestamp", "Make the match expand to the current date/time; use --timestamp=FORMAT to set the strftime format")
+	fs.BoolVar(&f.force, "force", false, "Append even if a trigger already exists or the file already ends with an identical entry")
+	fs.StringVar(&f.transform, "transform", "", "Comma-separated filters applied to the replacement in order: trim, collapse-ws, lower, upper, dedent")
+	fs.StringVar(&f.group, "group", "", "Add the match at the end of this `# === name ===` section, creating it if needed")
+	fs.BoolVar(&f.reload, "reload", false, "Restart espanso after writing the match, if it's running")
+	fs.IntVar(&f.foldAt, "fold-at", 0, "Store a long single-line replacement as a folded block scalar wrapped at this many characters")
+	fs.IntVar(&f.indent, "indent", 0, "Indent each non-blank line of the replacement by this many spaces")
+	fs.BoolVar(&f.safe, "safe", false, "Refuse to write to a match file outside the configured match dir")
+	fs.BoolVar(&f.validateDir, "validate-dir", false, "Validate every YAML file under the match dir; exit non-zero if any fail")
+	fs.Var(&f.extra, "extra", "Add a key=value pair to the match, e.g. word=true (repeatable)")
+	fs.Var(&f.vars, "var", "Add a var the replacement can use as {{name}}, as name=type; type is date or clipboard (repeatable)")
+	fs.BoolVar(&f.render, "render", false, "With --dry-run or --preview-file, also show the replacement with date and echo vars expanded")
+	fs.StringVar(&f.snip, "snip", "", "Use the text stored under this key in the snippet library as the replacement")
+	fs.BoolVar(&f.clipboard, "clipboard", false, "Use the text on the system clipboard as the replacement")
+	fs.BoolVar(&f.clipboard, "c", false, "Shorthand for --clipboard")
+	fs.BoolVar(&f.init, "init", false, "Set up cliesp: write the config file and create the match file, asking for their locations (--yes takes the defaults)")
+	fs.BoolVar(&f.touch, "touch", false, "Create the match file with its header if missing, then exit without prompting")
+	fs.BoolVar(&f.appendSorted, "append-sorted", false, "Insert the match at its sorted position by trigger instead of at the end")
+	fs.StringVar(&f.engine, "engine", "", "Placeholder syntax for --define and --template-file values: mustache ({{key}}) or dollar (${key})")
+	fs.Var(&f.define, "set", "Alias for --define")
+	fs.StringVar(&f.template, "template", "", "Build the match from a named template in ~/.config/cliesp/templates (or a path)")
+	fs.BoolVar(&f.strict, "strict", false, "Fail when a rendered template still has unresolved placeholders")
+	fs.Var(&f.define, "define", "Set a cliesp-time value, key=value, substituted into the replacement and template (repeatable)")
+	fs.BoolVar(&f.listByFile, "list-by-file", false, "List the triggers of every match file in the match dir, grouped by file, and exit")
+	fs.BoolVar(&f.showPlaceholders, "show-placeholders", false, "Report how many {{var}} placeholders and $|$ cursor markers the replacement has")
+	fs.BoolVar(&f.allowEmpty, "allow-empty", false, "Allow an empty replacement (emits replace: \"\")")
+	fs.BoolVar(&f.allowInvalidUTF8, "allow-invalid-utf8", false, "Append a replacement even if it is not valid UTF-8")
+}
+
+// checkSafePath enforces --safe: path must lie inside the configured match dir.
+func checkSafePath(path string, cfg AppConfig) error {
+	dir, err := expandHome(cfg.MatchDir)
+	if err != nil {
+		return err
+	}
+	return checkWithinDir(path, dir)
+}
+
+// backupBeforeWrite backs up the match file at path ahead of a write when
+// --backup or the backup setting asks for it, then prunes old backups and
+// keeps the backup dir's .gitignore up to date. It returns the backup's path
+// ("" when none was taken) and exitOK, or reports the failure on stderr and
+// returns its exit code.
+func backupBeforeWrite(stdout, stderr io.Writer, path string, flags cliFlags, cfg AppConfig) (string, int) {
+	if !flags.backup && !cfg.Backup {
+		return "", exitOK
+	}
+	// An empty file has nothing worth keeping.
+	if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+		return "", exitOK
+	}
+	dir := cfg.BackupDir
+	if flags.backupDir != "" {
+		dir = flags.backupDir
+	}
+	if dir != "" {
+		var err error
+		if dir, err = expandHome(dir); err != nil {
+			fmt.Fprintln(stderr, "error resolving backup dir:", err)
+			return "", exitPath
+		}
+	}
+	dst, err := backupFile(path, dir)
+	if err != nil {
+		fmt.Fprintln(stderr, "error backing up match file:", err)
+		return "", exitWrite
+	}
+	if !flags.quiet {
+		fmt.Fprintf(stdout, "Backed up %s to %s\n", path, dst)
+	}
+	if _, err := pruneBackups(path, dir, cfg.BackupKeep); err != nil {
+		fmt.Fprintln(stderr, "error pruning old backups:", err)
+		return dst, exitWrite
+	}
+	if !flags.noGitignore {
+		if err := ensureBackupGitignore(filepath.Dir(dst)); err != nil {
+			fmt.Fprintln(stderr, "error updating .gitignore:", err)
+			return dst, exitWrite
+		}
+	}
+	return dst, exitOK
 }
 
 // checkOpenConflict ensures mutually exclusive use of --open and --dir.
@@ -286,20 +1183,130 @@ func checkOpenConflict(openFile, openDir bool) error {
 	return nil
 }
 
-// usage prints a concise help message.
-func usage() {
-	fmt.Fprintf(os.Stderr, "cliesp - append espanso matches or open target file/dir\n\n")
-	fmt.Fprintf(os.Stderr, "Usage:\n  cliesp [flags]\n\n")
-	fmt.Fprintf(os.Stderr, "Flags:\n")
-	fmt.Fprintf(os.Stderr, "  -m, --matchFile string   Path to match file (dir or full file path) [flag > env/.env > config > defaults]\n")
-	fmt.Fprintf(os.Stderr, "  -o, --open               Open the resolved match file and exit\n")
-	fmt.Fprintf(os.Stderr, "  -d, --openDir            Open the resolved match directory and exit\n")
-	fmt.Fprintf(os.Stderr, "  -h, --help               Show this help message\n\n")
-	fmt.Fprintf(os.Stderr, "Configuration:\n")
-	fmt.Fprintf(os.Stderr, "  Config file: ~/.config/cliesp/settings.{yaml|yml|toml|json}\n")
-	fmt.Fprintf(os.Stderr, "  Env vars (.env supported): CLIESP_MATCH_DIR, CLIESP_MATCH_FILE, CLIESP_FILE_OPENER, CLIESP_DIR_OPENER\n")
-	fmt.Fprintf(os.Stderr, "  Defaults: dir='%s', file='%s'\n", defaultEspansoMatchDir, defaultEspansoMatchFile)
-	fmt.Fprintf(os.Stderr, "  Opener defaults: file=$EDITOR or 'vim'; dir=open|xdg-open|explorer (per platform)\n")
+// usage prints a concise help message to w.
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "cliesp - append espanso matches or open target file/dir\n\n")
+	fmt.Fprintf(w, "Usage:\n  cliesp [flags]\n\n")
+	fmt.Fprintf(w, "Flags:\n")
+	fmt.Fprintf(w, "  -m, --matchFile string   Path to match file (dir or full file path) [flag > env/.env > config > defaults]\n")
+	fmt.Fprintf(w, "  -P, --profile NAME       Use the match file configured for profile NAME (--matchFile wins)\n")
+	fmt.Fprintf(w, "  -o, --open               Open the resolved match file and exit\n")
+	fmt.Fprintf(w, "  -d, --openDir            Open the resolved match directory and exit\n")
+	fmt.Fprintf(w, "      --edit TRIGGER       Open the match file at the line defining TRIGGER and exit\n")
+	fmt.Fprintf(w, "      --no-validate        Skip the empty/whitespace/control-character trigger check\n")
+	fmt.Fprintf(w, "      --max-trigger-length int\n")
+	fmt.Fprintf(w, "                           Reject triggers longer than this (0 disables)\n")
+	fmt.Fprintf(w, "  -n, --dry-run            Print the snippet that would be appended and exit\n")
+	fmt.Fprintf(w, "      --preview-file       Print the resulting file without writing it\n")
+	fmt.Fprintf(w, "      --inject-vars=false  Emit inject_vars: false on the match's vars\n")
+	fmt.Fprintf(w, "      --usage logfile      Report trigger usage from an espanso log and exit\n")
+	fmt.Fprintf(w, "      --dedent             Strip common leading indentation from the replacement\n")
+	fmt.Fprintf(w, "      --straighten-quotes  Turn smart quotes in the replacement into straight ones\n")
+	fmt.Fprintf(w, "      --allow-empty        Allow an empty replacement (emits replace: \"\")\n")
+	fmt.Fprintf(w, "      --allow-invalid-utf8 Append a replacement that is not valid UTF-8\n")
+	fmt.Fprintf(w, "      --extends anchor     Merge keys from a YAML anchor in the match file\n")
+	fmt.Fprintf(w, "      --delete trigger     Remove the match defining trigger and exit\n")
+	fmt.Fprintf(w, "  -l, --list               List the triggers in the match file and exit\n")
+	fmt.Fprintf(w, "      --group-by-file      With --list, list the whole match dir grouped by file\n")
+	fmt.Fprintf(w, "      --count              Print the number of matches in the match file and exit\n")
+	fmt.Fprintf(w, "      --search QUERY       Show matches whose triggers or replacement contain QUERY and exit\n")
+	fmt.Fprintf(w, "      --list-recent        List match files by modification time and exit\n")
+	fmt.Fprintf(w, "      --code[=lang]        Wrap the replacement in a fenced code block\n")
+	fmt.Fprintf(w, "      --auto-code          Fence the replacement, guessing its language\n")
+	fmt.Fprintf(w, "      --lang-hint hint     Language for --auto-code, e.g. main.py, .py or python\n")
+	fmt.Fprintf(w, "      --lint               Check the match file for problems and exit\n")
+	fmt.Fprintf(w, "      --template-file path Build the match from a YAML fragment template\n")
+	fmt.Fprintf(w, "      --check-only         Lint without modifying anything; non-zero exit on issues\n")
+	fmt.Fprintf(w, "      --json-errors        With --check-only, print issues as JSON\n")
+	fmt.Fprintf(w, "      --raw-trigger        Read one trigger verbatim, keeping surrounding spaces\n")
+	fmt.Fprintf(w, "      --list-duplicates    List triggers defined more than once and exit\n")
+	fmt.Fprintf(w, "      --list-empty         List matches with an empty replacement and exit (non-zero if any)\n")
+	fmt.Fprintf(w, "      --menu               Show an interactive menu\n")
+	fmt.Fprintf(w, "      --print-yaml-for trigger\n")
+	fmt.Fprintf(w, "                           Print the YAML of the match with this trigger and exit\n")
+	fmt.Fprintf(w, "      --numbered-input     Number the lines while entering a replacement\n")
+	fmt.Fprintf(w, "      --replace-from-url url\n")
+	fmt.Fprintf(w, "                           Use the body fetched from url as the replacement\n")
+	fmt.Fprintf(w, "      --replace-from-command cmd\n")
+	fmt.Fprintf(w, "                           Run cmd now and use its output as the replacement\n")
+	fmt.Fprintf(w, "      --backup             Back up the match file before writing\n")
+	fmt.Fprintf(w, "      --backup-dir dir     Write backups to dir instead of next to the file\n")
+	fmt.Fprintf(w, "      --no-gitignore       Don't add *.bak-* to .gitignore next to backups\n")
+	fmt.Fprintf(w, "      --trigger-case-transform none|lower|upper\n")
+	fmt.Fprintf(w, "                           Change the case of triggers before checking and writing\n")
+	fmt.Fprintf(w, "      --prefix p           Prepend p to triggers lacking it, e.g. \":\" (\"\" disables)\n")
+	fmt.Fprintf(w, "      --config-schema      Print the available config keys and exit\n")
+	fmt.Fprintf(w, "      --output-only        Print the snippet only; no config or match file is used\n")
+	fmt.Fprintf(w, "      --emit-to path       With --output-only, write the snippet to path\n")
+	fmt.Fprintf(w, "      --dump-fixtures dir  Write sample match files for manual testing and exit\n")
+	fmt.Fprintf(w, "      --with-id            Tag the new match with a stable cliesp-id comment\n")
+	fmt.Fprintf(w, "      --id                 Look up the --edit or --delete match by its cliesp-id\n")
+	fmt.Fprintf(w, "      --include-file path  Expand to the contents of path (read at expansion time)\n")
+	fmt.Fprintf(w, "      --inline             With --include-file, copy the contents in now\n")
+	fmt.Fprintf(w, "      --ask-mode           Choose the multiline input mode before typing the replacement\n")
+	fmt.Fprintf(w, "      --preview-width n    Show at most n characters of each replacement in listings\n")
+	fmt.Fprintf(w, "      --replace-from-editor-template path\n")
+	fmt.Fprintf(w, "                           Edit a copy of a template in $EDITOR and use it\n")
+	fmt.Fprintf(w, "      --from-history       Reuse a recent replacement from a numbered list\n")
+	fmt.Fprintf(w, "      --sort-triggers      Sort the triggers of a multi-trigger entry\n")
+	fmt.Fprintf(w, "      --timestamp[=format] Expand to the current date/time (default format from config)\n")
+	fmt.Fprintf(w, "      --force              Append despite duplicate triggers or an identical entry in place\n")
+	fmt.Fprintf(w, "      --transform list     Filter the replacement, e.g. trim,collapse-ws\n")
+	fmt.Fprintf(w, "      --group name         Add the match under a '# === name ===' section\n")
+	fmt.Fprintf(w, "      --reload             Restart espanso after writing\n")
+	fmt.Fprintf(w, "      --indent n           Indent each line of the replacement by n spaces\n")
+	fmt.Fprintf(w, "      --fold-at n          Wrap a long single-line replacement at n characters in the file (>- folding)\n")
+	fmt.Fprintf(w, "      --safe               Refuse to write outside the match dir\n")
+	fmt.Fprintf(w, "      --validate-dir       Validate every match file in the match dir and exit\n")
+	fmt.Fprintf(w, "      --extra key=value    Add a key to the match, e.g. word=true (repeatable)\n")
+	fmt.Fprintf(w, "      --var name=type      Add a date or clipboard var used as {{name}} (repeatable)\n")
+	fmt.Fprintf(w, "      --render             With --dry-run or --preview-file, show the replacement with vars expanded\n")
+	fmt.Fprintf(w, "      --snip key           Use the snippet library text for key as the replacement\n")
+	fmt.Fprintf(w, "  -c, --clipboard          Use the clipboard contents as the replacement\n")
+	fmt.Fprintf(w, "      --init               Create the config and match file for first-time setup\n")
+	fmt.Fprintf(w, "      --touch              Create the match file (with header) and exit\n")
+	fmt.Fprintf(w, "      --append-sorted      Insert the match in trigger order instead of at the end\n")
+	fmt.Fprintf(w, "      --define key=value   Substitute a value into the replacement/template (repeatable)\n")
+	fmt.Fprintf(w, "      --set key=value      Alias for --define\n")
+	fmt.Fprintf(w, "      --template name      Build the match from a named template\n")
+	fmt.Fprintf(w, "      --strict             Fail on unresolved template placeholders\n")
+	fmt.Fprintf(w, "      --engine name        Placeholder syntax: mustache ({{key}}) or dollar (${key})\n")
+	fmt.Fprintf(w, "      --list-by-file       List triggers across the match dir, grouped by file\n")
+	fmt.Fprintf(w, "      --show-placeholders  Count {{var}} placeholders and $|$ markers before writing\n")
+	fmt.Fprintf(w, "  -t, --trigger text       Trigger to add instead of prompting (repeatable)\n")
+	fmt.Fprintf(w, "  -r, --replace text       Replacement instead of prompting; - reads stdin, @path a file\n")
+	fmt.Fprintf(w, "      --line text          One replacement line (repeatable), joined with newlines\n")
+	fmt.Fprintf(w, "      --auto-target        Use the only existing match file if the default is missing\n")
+	fmt.Fprintf(w, "  -p, --propagate-case     Also match case variations of the trigger\n")
+	fmt.Fprintf(w, "  -w, --word               Only expand the trigger as a whole word\n")
+	fmt.Fprintf(w, "      --label text         Label shown for the match in espanso's search\n")
+	fmt.Fprintf(w, "      --backfill-labels    Add derived labels to matches that have none and exit\n")
+	fmt.Fprintf(w, "      --label-rule rule    Label derivation for --backfill-labels: words, trigger or triggers\n")
+	fmt.Fprintf(w, "      --show-context       Show the target file and input mode before prompting\n")
+	fmt.Fprintf(w, "  -x, --regex [pattern]    Match a regex pattern instead of a trigger\n")
+	fmt.Fprintf(w, "  -q, --quiet              Don't print progress messages or the summary\n")
+	fmt.Fprintf(w, "  -y, --yes                Skip confirmation prompts\n")
+	fmt.Fprintf(w, "  -h, --help               Show this help message\n\n")
+	fmt.Fprintf(w, "Configuration:\n")
+	fmt.Fprintf(w, "  Config file: ~/.config/cliesp/settings.{yaml|yml|toml|json} ($XDG_CONFIG_HOME/cliesp if set)\n")
+	fmt.Fprintf(w, "  Env vars (.env supported): CLIESP_MATCH_DIR, CLIESP_MATCH_FILE, CLIESP_FILE_OPENER, CLIESP_DIR_OPENER\n")
+	fmt.Fprintf(w, "  Defaults: dir='%s', file='%s'\n", defaultEspansoMatchDir, defaultEspansoMatchFile)
+	fmt.Fprintf(w, "  Opener defaults: file=$EDITOR or 'vim'; dir=open|xdg-open|explorer (per platform)\n")
+}
+
+// newFileSummary describes a match file that is about to be created, along
+// with the resolved settings that led to it.
+func newFileSummary(path string, cfg AppConfig) string {
+	mode := cfg.MultilineMode
+	if mode == "" {
+		mode = defaultMultilineMode
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Match file %s does not exist yet.\n", path))
+	b.WriteString(fmt.Sprintf("  match_dir:      %s\n", cfg.MatchDir))
+	b.WriteString(fmt.Sprintf("  match_file:     %s\n", cfg.MatchFile))
+	b.WriteString(fmt.Sprintf("  multiline_mode: %s\n", mode))
+	return b.String()
 }
 
 // pickFileOpener selects the command to open a file based on config and env.
@@ -328,15 +1335,59 @@ func pickDirOpener(cfg AppConfig) string {
 	}
 }
 
+// pickExistingTarget is consulted when the default match file doesn't exist.
+// A single other match file in its directory is offered (or used outright
+// with auto); several are listed to choose from. Without candidates, or
+// when nobody is there to answer, def is kept.
+func pickExistingTarget(in *bufio.Reader, out io.Writer, def string, auto, nonInteractive bool) (string, error) {
+	if p, ok, err := autoTarget(filepath.Dir(def)); err != nil || ok {
+		if err != nil {
+			return "", err
+		}
+		if auto {
+			fmt.Fprintf(out, "Using %s\n", p)
+			return p, nil
+		}
+		if nonInteractive {
+			return def, nil
+		}
+		if confirm(in, out, fmt.Sprintf("%s doesn't exist. Use %s instead?", def, filepath.Base(p))) {
+			return p, nil
+		}
+		return def, nil
+	}
+	candidates, err := matchCandidates(filepath.Dir(def))
+	if err != nil || len(candidates) == 0 || nonInteractive {
+		return def, err
+	}
+	return chooseTarget(in, out, candidates, def)
+}
+
+// readAllInput reads the replacement from in until EOF, minus a single
+// trailing newline, so `--replace -` can take piped text.
+func readAllInput(in io.Reader) (string, error) {
+	b, err := io.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
 // runOpen executes an opener command with the target path. If the opener contains
 // spaces (e.g., "code -w"), it splits into command and args.
 func runOpen(opener, target string) error {
+	return runOpenArgs(opener, target)
+}
+
+// runOpenArgs is runOpen with several arguments after the opener's own,
+// such as a line number and the file.
+func runOpenArgs(opener string, targetArgs ...string) error {
 	parts := strings.Fields(opener)
 	if len(parts) == 0 {
 		return fmt.Errorf("invalid opener command")
 	}
 	name := parts[0]
-	args := append(parts[1:], target)
+	args := append(parts[1:], targetArgs...)
 	if _, err := exec.LookPath(name); err != nil {
 		return fmt.Errorf("could not find opener '%s' in PATH", name)
 	}
@@ -352,78 +1403,603 @@ func main() {
 	// loading is skipped. Missing files are ignored by godotenv.Load.
 	_ = godotenv.Load(".env", ".env.local", ".env.production")
 
-	// Flags
-	var matchFlag string
-	var openFlag bool
-	var dirFlag bool
-	flag.Usage = usage
-	defineFlags(flag.CommandLine, &matchFlag, &openFlag, &dirFlag)
-	// Allow intermixing flags and prompts
-	flag.Parse()
-
-	// Load config from files/env via cliutils/config
-	cfg, err := cfgpkg.Load(cfgpkg.Options[AppConfig]{
-		AppName: "cliesp",
-		ConsumerConfig: AppConfig{
-			MatchDir:      defaultEspansoMatchDir,
-			MatchFile:     defaultEspansoMatchFile,
-			MultilineMode: defaultMultilineMode,
-		},
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// detachedBoolValue reports the name of a bool flag given its value as a
+// separate argument, as in `--inject-vars false`. The flag package reads
+// that as --inject-vars=true and stops parsing at the stray value, so it has
+// to be rejected rather than quietly ignored. all is what fs parsed.
+func detachedBoolValue(fs *flag.FlagSet, all []string) (string, bool) {
+	rest := fs.Args()
+	i := len(all) - len(rest)
+	if len(rest) == 0 || i == 0 || (rest[0] != "true" && rest[0] != "false") {
+		return "", false
+	}
+	name := strings.TrimLeft(all[i-1], "-")
+	f := fs.Lookup(name)
+	if f == nil || f.Name == "regex" || f.Name == "x" {
+		// --regex takes its pattern as an argument, which may be "true".
+		return "", false
+	}
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return name, true
+	}
+	return "", false
+}
+
+// run executes cliesp with the given arguments and streams, and returns the
+// process exit code. main is a thin wrapper so the whole flow can be driven
+// from tests.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	// Load config from files/env via cliutils/config. --output-only runs on
+	// the defaults so it never touches the filesystem.
+	cfg := defaultConfig()
+	if !outputOnlyRequested(args) {
+		dir, err := configDir()
+		if err != nil {
+			fmt.Fprintln(stderr, "error locating config:", err)
+			return exitConfig
+		}
+		ldr := cfgpkg.NewLoader(cfgpkg.Options[AppConfig]{
+			AppName:        "cliesp",
+			ConsumerConfig: defaultConfig(),
+		})
+		ldr.SetConfigPath(dir)
+		cfg, err = ldr.Load()
+		if err != nil {
+			fmt.Fprintln(stderr, "error loading config:", err)
+			return exitConfig
+		}
+		if env := os.Getenv(configEnvVar); env != "" {
+			if err := applyEnvConfig(&cfg, dir, env); err != nil {
+				fmt.Fprintln(stderr, "error loading config:", err)
+				return exitConfig
+			}
+		}
+	}
+
+	// Flags. Configured default flags come first so explicit ones override them.
+	defaults, err := splitArgs(cfg.DefaultFlags)
+	if err != nil {
+		fmt.Fprintln(stderr, "error parsing default_flags:", err)
+		return exitConfig
+	}
+	var flags cliFlags
+	fs := flag.NewFlagSet("cliesp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usage(stderr) }
+	defineFlags(fs, &flags)
+	allArgs := append(defaults, args...)
+	if err := fs.Parse(allArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if name, ok := detachedBoolValue(fs, allArgs); ok {
+		fmt.Fprintf(stderr, "--%s takes its value after \"=\", e.g. --%s=%s\n", name, name, fs.Arg(0))
+		return exitUsage
+	}
+	// An explicit --replace "" still counts, so --allow-empty can apply.
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "replace" || f.Name == "r" {
+			flags.replaceSet = true
+		}
+		if f.Name == "prefix" {
+			flags.prefixSet = true
+		}
 	})
+	if flags.completion == completionProfiles {
+		for _, name := range sortedKeys(cfg.Profiles) {
+			fmt.Fprintln(stdout, name)
+		}
+		return exitOK
+	}
+	if flags.completion != "" {
+		script, err := generateCompletion(flags.completion)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		fmt.Fprint(stdout, script)
+		return exitOK
+	}
+	if flags.replaceSet && len(flags.line) > 0 {
+		fmt.Fprintln(stderr, "--replace and --line cannot be used together")
+		return exitUsage
+	}
+	// With both the triggers and the replacement on the command line there
+	// is nobody at a terminal to answer prompts.
+	nonInteractive := len(flags.trigger) > 0 && (flags.replaceSet || len(flags.line) > 0 || flags.clipboard)
+	ef, err := parseEntryFlags(flags, cfg)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error loading config:", err)
-		os.Exit(1)
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if flags.configSchema {
+		printConfigSchema(stdout, describeConfig())
+		return exitOK
+	}
+	if flags.outputOnly {
+		return runOutputOnly(flags, ef, cfg, fs.Args(), bufio.NewReader(stdin), stdout, stderr)
+	}
+	if flags.dumpFixtures != "" {
+		dir, err := expandHome(flags.dumpFixtures)
+		if err != nil {
+			fmt.Fprintln(stderr, "error resolving fixture dir:", err)
+			return exitPath
+		}
+		paths, err := dumpFixtures(dir)
+		if err != nil {
+			fmt.Fprintln(stderr, "error writing fixtures:", err)
+			return exitWrite
+		}
+		for _, p := range paths {
+			fmt.Fprintf(stdout, "Wrote %s\n", p)
+		}
+		return exitOK
+	}
+
+	in := bufio.NewReader(stdin)
+
+	if flags.init {
+		return runInit(in, stdout, stderr, cfg, flags.yes)
 	}
 
 	// Resolve final match path using precedence: flag > env/config > defaults
-	filePath, err := resolveMatchPath(matchFlag, cfg)
+	filePath, err := resolveMatchPath(flags.matchPath, cfg)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error resolving match file path:", err)
-		os.Exit(1)
+		fmt.Fprintln(stderr, "error resolving match file path:", err)
+		return exitPath
+	}
+	if flags.matchPath == "" && flags.profile != "" {
+		filePath, err = resolveProfile(flags.profile, cfg)
+		if err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+			return exitConfig
+		}
+	}
+
+	// The interactive setting only applies to a bare `cliesp`, so scripted
+	// runs with flags are never stopped by the menu.
+	menuAdd := false
+	if flags.menu || (cfg.Interactive && len(args) == 0) {
+		action, err := runMenu(in, stdout)
+		if err != nil {
+			fmt.Fprintln(stderr, "error reading menu choice:", err)
+			return exitError
+		}
+		switch action {
+		case menuAddMatch:
+			menuAdd = true
+		case menuQuit:
+			return exitOK
+		case menuList:
+			matches, err := parseMatchFile(filePath)
+			if err != nil {
+				fmt.Fprintln(stderr, "error parsing match file:", err)
+				return exitError
+			}
+			printTriggers(stdout, matches, resolvePreviewWidth(flags.previewWidth, cfg.PreviewWidth))
+			return exitOK
+		case menuOpenFile:
+			flags.open = true
+		case menuOpenDir:
+			flags.openDir = true
+		case menuEditConfig:
+			cfgPath, err := configFilePath()
+			if err != nil {
+				fmt.Fprintln(stderr, "error locating config file:", err)
+				return exitError
+			}
+			if err := runOpen(pickFileOpener(cfg), cfgPath); err != nil {
+				fmt.Fprintln(stderr, "failed to open:", err)
+				return exitExternal
+			}
+			return exitOK
+		}
+	}
+
+	if flags.listRecent {
+		files, err := recentFiles(filepath.Dir(filePath))
+		if err != nil {
+			fmt.Fprintln(stderr, "error listing match files:", err)
+			return exitError
+		}
+		printRecentFiles(stdout, files)
+		return exitOK
 	}
 
-	if err := ensureFileWithHeader(filePath); err != nil {
-		fmt.Fprintln(os.Stderr, "error preparing file:", err)
-		os.Exit(1)
+	if flags.list && !flags.groupByFile {
+		matches, err := parseMatchFile(filePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(stderr, "error parsing match file:", err)
+			return exitError
+		}
+		printTriggers(stdout, matches, resolvePreviewWidth(flags.previewWidth, cfg.PreviewWidth))
+		return exitOK
+	}
+
+	if flags.count {
+		matches, err := parseMatchFile(filePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(stderr, "error parsing match file:", err)
+			return exitError
+		}
+		fmt.Fprintln(stdout, len(matches))
+		return exitOK
+	}
+
+	if flags.search != "" {
+		matches, err := parseMatchFile(filePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(stderr, "error parsing match file:", err)
+			return exitError
+		}
+		printSearchHits(stdout, searchMatches(matches, flags.search), flags.search)
+		return exitOK
+	}
+
+	if flags.listByFile || (flags.list && flags.groupByFile) {
+		byFile, err := collectDirTriggers(filepath.Dir(filePath))
+		if err != nil {
+			fmt.Fprintln(stderr, "error parsing match files:", err)
+			return exitError
+		}
+		printTriggersByFile(stdout, byFile)
+		return exitOK
+	}
+
+	if flags.validateDir {
+		results, err := validateDir(filepath.Dir(filePath))
+		if err != nil {
+			fmt.Fprintln(stderr, "error validating match dir:", err)
+			return exitError
+		}
+		if !printFileResults(stdout, results) {
+			return exitValidation
+		}
+		return exitOK
+	}
+
+	if flags.checkOnly {
+		return runCheck(stdout, stderr, filePath, flags.jsonErrors)
+	}
+
+	if flags.printYAMLFor != "" {
+		out, err := printMatchYAML(filePath, flags.printYAMLFor)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		fmt.Fprint(stdout, out)
+		return exitOK
+	}
+
+	if flags.listDuplicates {
+		dups, err := findDuplicateTriggers(filePath)
+		if err != nil {
+			fmt.Fprintln(stderr, "error parsing match file:", err)
+			return exitError
+		}
+		printDuplicates(stdout, dups)
+		return exitOK
+	}
+
+	if flags.listEmpty {
+		matches, err := parseMatchFile(filePath)
+		if err != nil {
+			fmt.Fprintln(stderr, "error parsing match file:", err)
+			return exitError
+		}
+		empty := emptyMatches(matches)
+		printEmptyMatches(stdout, empty)
+		if len(empty) > 0 {
+			return exitValidation
+		}
+		return exitOK
+	}
+
+	if flags.lint {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Fprintln(stderr, "error reading match file:", err)
+			return exitError
+		}
+		issues := lintContent(content)
+		printLintIssues(stdout, filePath, issues)
+		if len(issues) > 0 {
+			return exitValidation
+		}
+		return exitOK
+	}
+
+	if flags.usageLog != "" {
+		counts, err := parseUsage(flags.usageLog)
+		if err != nil {
+			fmt.Fprintln(stderr, "error reading usage log:", err)
+			return exitError
+		}
+		matches, err := parseMatchFile(filePath)
+		if err != nil {
+			fmt.Fprintln(stderr, "error parsing match file:", err)
+			return exitError
+		}
+		printUsageReport(stdout, matches, counts)
+		return exitOK
+	}
+
+	if flags.byID && flags.edit == "" && flags.delete == "" {
+		fmt.Fprintln(stderr, "--id needs --edit or --delete")
+		return exitUsage
+	}
+
+	if flags.delete != "" {
+		if flags.safe || cfg.Safe {
+			if err := checkSafePath(filePath, cfg); err != nil {
+				fmt.Fprintln(stderr, "refusing to write:", err)
+				return exitPath
+			}
+		}
+		m, err := findEntry(filePath, flags.delete, flags.byID)
+		if err != nil {
+			fmt.Fprintln(stderr, "error deleting match:", err)
+			return exitError
+		}
+		entry, err := matchEntryText(filePath, m.Line)
+		if err != nil {
+			fmt.Fprintln(stderr, "error deleting match:", err)
+			return exitError
+		}
+		desc := fmt.Sprintf("This entry will be removed from %s:\n%s", filePath, entry)
+		ok, err := confirmDestructive(in, stdout, desc, flags.force || flags.yes || !cfg.RequireConfirm)
+		if err != nil {
+			fmt.Fprintln(stderr, "error reading confirmation:", err)
+			return exitError
+		}
+		if !ok {
+			fmt.Fprintln(stderr, "aborted")
+			return exitError
+		}
+		backup, code := backupBeforeWrite(stdout, stderr, filePath, flags, cfg)
+		if code != exitOK {
+			return code
+		}
+		if err := deleteMatch(filePath, m.Line); err != nil {
+			fmt.Fprintln(stderr, "error deleting match:", err)
+			return exitWrite
+		}
+		if !flags.quiet {
+			if keys := m.AllTriggers(); flags.byID && len(keys) > 0 {
+				fmt.Fprintf(stdout, "Deleted the match for %q (id %s) from %s\n", keys[0], flags.delete, filePath)
+			} else {
+				fmt.Fprintf(stdout, "Deleted the match for %q from %s\n", flags.delete, filePath)
+			}
+			printSummary(stdout, ChangeSummary{File: filePath, Operation: "delete", Triggers: m.AllTriggers(), Backup: backup})
+		}
+		return exitOK
+	}
+
+	if flags.backfillLabels {
+		if flags.safe || cfg.Safe {
+			if err := checkSafePath(filePath, cfg); err != nil {
+				fmt.Fprintln(stderr, "refusing to write:", err)
+				return exitPath
+			}
+		}
+		rule := cfg.LabelRule
+		if flags.labelRule != "" {
+			rule = flags.labelRule
+		}
+		if _, err := deriveLabel([]string{":x"}, rule); err != nil {
+			fmt.Fprintln(stderr, "invalid label rule:", err)
+			return exitUsage
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Fprintln(stderr, "error reading match file:", err)
+			return exitError
+		}
+		updated, labelled, err := backfillLabels(content, rule)
+		if err != nil {
+			fmt.Fprintln(stderr, "error adding labels:", err)
+			return exitError
+		}
+		var backup string
+		if len(labelled) > 0 {
+			desc := fmt.Sprintf("%d match(es) in %s will get a derived label.", len(labelled), filePath)
+			ok, err := confirmDestructive(in, stdout, desc, flags.force || flags.yes || !cfg.RequireConfirm)
+			if err != nil {
+				fmt.Fprintln(stderr, "error reading confirmation:", err)
+				return exitError
+			}
+			if !ok {
+				fmt.Fprintln(stderr, "aborted")
+				return exitError
+			}
+			var code int
+			if backup, code = backupBeforeWrite(stdout, stderr, filePath, flags, cfg); code != exitOK {
+				return code
+			}
+			if err := os.WriteFile(filePath, updated, 0o644); err != nil {
+				fmt.Fprintln(stderr, "error adding labels:", err)
+				return exitWrite
+			}
+		}
+		if !flags.quiet {
+			fmt.Fprintf(stdout, "Added %d label(s) to %s\n", len(labelled), filePath)
+			if len(labelled) > 0 {
+				printSummary(stdout, ChangeSummary{File: filePath, Operation: "backfill-labels", Triggers: labelled, Backup: backup})
+			}
+		}
+		return exitOK
+	}
+
+	if flags.touch {
+		if flags.safe || cfg.Safe {
+			if err := checkSafePath(filePath, cfg); err != nil {
+				fmt.Fprintln(stderr, "refusing to write:", err)
+				return exitPath
+			}
+		}
+		created, err := ensureFileWithHeader(filePath, cfg.CreateMissingDirs)
+		if err != nil {
+			fmt.Fprintln(stderr, "error preparing file:", err)
+			return exitWrite
+		}
+		if created {
+			fmt.Fprintf(stdout, "Created %s\n", filePath)
+		} else {
+			fmt.Fprintf(stdout, "%s already exists\n", filePath)
+		}
+		return exitOK
 	}
 
 	// If open/dir flags were provided, enforce mutual exclusion and open accordingly
-	if err := checkOpenConflict(openFlag, dirFlag); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(2)
+	if err := checkOpenConflict(flags.open, flags.openDir); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if flags.edit != "" {
+		m, err := findEntry(filePath, flags.edit, flags.byID)
+		if err != nil {
+			fmt.Fprintln(stderr, "error finding match:", err)
+			return exitError
+		}
+		opener := pickFileOpener(cfg)
+		if err := runOpenArgs(opener, openAtLineArgs(opener, filePath, m.Line)...); err != nil {
+			fmt.Fprintln(stderr, "failed to open:", err)
+			return exitExternal
+		}
+		fmt.Fprintf(stdout, "Opened %s at line %d\n", filePath, m.Line)
+		return exitOK
 	}
-	if openFlag || dirFlag {
+
+	if flags.open || flags.openDir {
+		if _, err := ensureFileWithHeader(filePath, cfg.CreateMissingDirs); err != nil {
+			fmt.Fprintln(stderr, "error preparing file:", err)
+			return exitWrite
+		}
 		target := filePath
-		if dirFlag {
+		if flags.openDir {
 			target = filepath.Dir(filePath)
 		}
 		opener := pickFileOpener(cfg)
-		if dirFlag {
+		if flags.openDir {
 			opener = pickDirOpener(cfg)
 		}
 		if err := runOpen(opener, target); err != nil {
-			fmt.Fprintln(os.Stderr, "failed to open:", err)
-			os.Exit(1)
+			fmt.Fprintln(stderr, "failed to open:", err)
+			return exitExternal
 		}
-		fmt.Printf("Opened %s\n", target)
-		return
+		fmt.Fprintf(stdout, "Opened %s\n", target)
+		return exitOK
 	}
 
-	triggersLine, err := prompt("triggers? (space separated list of strings): ")
+	if flags.showContext {
+		mode := cfg.MultilineMode
+		if mode == "" {
+			mode = defaultMultilineMode
+		}
+		files, err := recentFiles(filepath.Dir(filePath))
+		if err != nil {
+			fmt.Fprintln(stderr, "error listing match files:", err)
+			return exitError
+		}
+		fmt.Fprintln(stdout, contextBanner(filePath, mode, len(files)))
+	}
+
+	triggers, err := readTriggers(in, stdout, flags, fs.Args())
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error reading triggers:", err)
-		os.Exit(1)
+		fmt.Fprintln(stderr, "error reading triggers:", err)
+		return exitError
 	}
-	var triggers []string
-	for _, part := range strings.Fields(triggersLine) {
-		p := strings.TrimSpace(part)
-		if p != "" {
-			triggers = append(triggers, p)
+	triggers, code := prepareTriggers(stderr, triggers, flags, cfg)
+	if code != exitOK {
+		return code
+	}
+	// Without an explicit --matchFile or --profile, the first trigger may
+	// route the match to a file configured for its prefix.
+	if flags.matchPath == "" && flags.profile == "" && !flags.regex {
+		if p, ok := routeByPrefix(triggers[0], cfg.Routes, cfg); ok {
+			filePath = p
+		} else if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
+			// Rather than starting a new default file next to existing
+			// ones, offer to use one of those.
+			filePath, err = pickExistingTarget(in, stdout, filePath, flags.autoTarget, nonInteractive)
+			if err != nil {
+				fmt.Fprintln(stderr, "error choosing match file:", err)
+				return exitError
+			}
 		}
 	}
-	if len(triggers) == 0 {
-		fmt.Fprintln(os.Stderr, "no triggers provided, exiting")
-		os.Exit(1)
+
+	if flags.safe || cfg.Safe {
+		if err := checkSafePath(filePath, cfg); err != nil {
+			fmt.Fprintln(stderr, "refusing to write:", err)
+			return exitPath
+		}
+	}
+
+	// Previewing must not create anything on disk
+	created := false
+	if !flags.previewFile && !flags.dryRun {
+		created, err = ensureFileWithHeader(filePath, cfg.CreateMissingDirs)
+		if err != nil {
+			fmt.Fprintln(stderr, "error preparing file:", err)
+			return exitWrite
+		}
+	}
+
+	// A brand-new file gets a one-time confirmation so a wrong path doesn't
+	// silently start a new match file.
+	if created && !flags.yes && !nonInteractive {
+		fmt.Fprint(stdout, newFileSummary(filePath, cfg))
+		if !confirm(in, stdout, "Create it and continue?") {
+			_ = os.Remove(filePath)
+			fmt.Fprintln(stderr, "aborted")
+			return exitError
+		}
+	}
+
+	existing, err := loadExistingTriggers(filePath)
+	if err != nil {
+		fmt.Fprintln(stderr, "error reading existing matches:", err)
+		return exitError
+	}
+	// The entry is written at the end of its --group, at its sorted
+	// position, or at the end of the file. With --append-sorted it is keyed
+	// by the trigger written first, which --sort-triggers picks in the same
+	// order the file is kept in.
+	at := insertPoint{Section: flags.group}
+	if (flags.appendSorted || cfg.AppendSorted) && flags.group == "" {
+		at.SortKey = triggers[0]
+		if flags.sortTriggers {
+			at.SortKey = slices.MinFunc(triggers, triggerCompare)
+		}
+	}
+	last, err := lastMatchTriggers(filePath, at)
+	if err != nil {
+		fmt.Fprintln(stderr, "error reading existing matches:", err)
+		return exitError
+	}
+	// Previews report duplicates as findings instead of refusing. A
+	// trigger of the entry just before the insert point may be an identical
+	// re-run, which is only known once the entry is built, so that check is
+	// deferred.
+	dupOfLast := ""
+	for _, t := range triggers {
+		if !existing[t] || flags.previewFile || flags.dryRun || flags.force {
+			continue
+		}
+		if !last[t] {
+			fmt.Fprintf(stderr, "trigger %q already exists in %s, not appending (use --force to append anyway)\n", t, filePath)
+			return exitValidation
+		}
+		if dupOfLast == "" {
+			dupOfLast = t
+		}
 	}
 
 	// Determine multiline mode from config
@@ -431,24 +2007,175 @@ func main() {
 	if mode == "" {
 		mode = defaultMultilineMode
 	}
+	if flags.askMode && !flags.replaceSet && len(flags.line) == 0 && flags.replaceFromURL == "" && flags.replaceFromCmd == "" && flags.editorTemplate == "" && flags.includeFile == "" && !flags.fromHistory && !flags.timestamp.enabled && flags.snip == "" && !flags.clipboard {
+		if mode, err = askMultilineMode(in, stdout, mode); err != nil {
+			fmt.Fprintln(stderr, "error choosing multiline mode:", err)
+			return exitError
+		}
+	}
 
-	replaceStr, err := promptMultiline("replace with? (supports multiline): ", mode)
+	replaceStr, vars, err := readReplace(in, stdout, stderr, flags, cfg, mode)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error reading replace string:", err)
-		os.Exit(1)
+		fmt.Fprintln(stderr, "error reading replace string:", err)
+		return exitError
 	}
 
-	entry := buildYAMLSnippet(triggers, replaceStr)
+	// Someone adding a match at a terminal (or from the menu) is also asked
+	// about the optional match settings their flags don't already set.
+	askOptions := menuAdd || (!nonInteractive && isTerminal(stdin))
+	if askOptions && flags.label == "" {
+		if flags.label, err = prompt(in, stdout, "label? (optional): "); err != nil {
+			fmt.Fprintln(stderr, "error reading label:", err)
+			return exitError
+		}
+	}
+	if askOptions && !flags.propagateCase {
+		flags.propagateCase = confirm(in, stdout, "propagate case?")
+	}
+	if askOptions && !flags.word {
+		flags.word = confirm(in, stdout, "whole words only?")
+	}
+
+	if flags.extends != "" {
+		if err := checkAnchor(filePath, flags.extends); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitValidation
+		}
+	}
+
+	built, code := buildEntry(stderr, triggers, replaceStr, vars, flags, ef, cfg)
+	if code != exitOK {
+		return code
+	}
+	entry, replaceStr, vars := built.Text, built.Replace, built.Vars
 
-	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0o644)
+	if flags.showPlaceholders {
+		nVars, nCursors := countPlaceholders(replaceStr)
+		fmt.Fprintf(stdout, "Placeholders: %d var(s), %d cursor marker(s)\n", nVars, nCursors)
+	}
+
+	if flags.dryRun {
+		// Exactly the bytes that would be appended. Findings in the file as
+		// it would then look go to stderr and don't fail the preview.
+		fmt.Fprint(stdout, entry)
+		if content, err := buildProposedContent(filePath, entry); err != nil {
+			fmt.Fprintf(stderr, "warning: %s: %s\n", filePath, err)
+		} else {
+			for _, issue := range lintContent([]byte(content)) {
+				fmt.Fprintf(stderr, "warning: %s: %s\n", filePath, issue)
+			}
+		}
+		if flags.render {
+			fmt.Fprintf(stdout, "\n--- rendered replacement ---\n%s\n", renderReplace(replaceStr, vars, time.Now()))
+		}
+		return exitOK
+	}
+
+	if flags.previewFile {
+		issues, err := previewFile(stdout, filePath, entry)
+		if err != nil {
+			fmt.Fprintln(stderr, "error previewing file:", err)
+			return exitValidation
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(stderr, "warning: %s: %s\n", filePath, issue)
+		}
+		if flags.render {
+			fmt.Fprintf(stdout, "\n--- rendered replacement ---\n%s\n", renderReplace(replaceStr, vars, time.Now()))
+		}
+		return exitOK
+	}
+
+	if !flags.force {
+		same, err := lastEntryEquals(filePath, at, entry)
+		if err != nil {
+			fmt.Fprintln(stderr, "error reading match file:", err)
+			return exitError
+		}
+		if same {
+			fmt.Fprintf(stdout, "An identical entry is already in place in %s, not appending (use --force to append anyway)\n", filePath)
+			return exitOK
+		}
+		if dupOfLast != "" {
+			fmt.Fprintf(stderr, "trigger %q already exists in %s, not appending (use --force to append anyway)\n", dupOfLast, filePath)
+			return exitValidation
+		}
+	}
+
+	if !flags.yes && !nonInteractive && cfg.ConfirmAppend {
+		fmt.Fprint(stdout, strings.TrimPrefix(entry, "\n"))
+		if !confirm(in, stdout, fmt.Sprintf("Append this match to %s?", filePath)) {
+			if created {
+				_ = os.Remove(filePath)
+			}
+			fmt.Fprintln(stderr, "aborted")
+			return exitError
+		}
+	}
+
+	backup, code := backupBeforeWrite(stdout, stderr, filePath, flags, cfg)
+	if code != exitOK {
+		return code
+	}
+	summary := ChangeSummary{File: filePath, Operation: "append", Triggers: triggers, Backup: backup}
+
+	if at.SortKey != "" {
+		err = insertSortedByTrigger(filePath, at.SortKey, entry)
+	} else {
+		err = appendEntry(filePath, at.Section, entry)
+	}
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error opening file for append:", err)
-		os.Exit(1)
+		fmt.Fprintln(stderr, "error writing entry:", err)
+		return exitWrite
+	}
+	if cfg.EnsureFinalNewline {
+		if err := normalizeFinalNewline(filePath); err != nil {
+			fmt.Fprintln(stderr, "error normalizing end of file:", err)
+			return exitWrite
+		}
 	}
-	defer f.Close()
-	if _, err := f.WriteString(entry); err != nil {
-		fmt.Fprintln(os.Stderr, "error writing entry:", err)
-		os.Exit(1)
+	if replaceStr != "" && len(vars) == 0 {
+		histPath, err := historyFilePath()
+		if err == nil {
+			err = appendHistory(histPath, replaceStr)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "warning: could not record history:", err)
+		}
+	}
+	if !flags.quiet {
+		fmt.Fprintf(stdout, "Appended %d trigger(s) to %s\n", len(triggers), filePath)
+		printSummary(stdout, summary)
+	}
+
+	// The match is written by now, so a failed reload is only a warning.
+	reloadCmd := strings.TrimSpace(cfg.ReloadCommand)
+	if (flags.reload || cfg.Reload) && reloadCmd != "" && reloadCmd != reloadDisabled {
+		if strings.Fields(reloadCmd)[0] == "espanso" {
+			if _, err := lookPath("espanso"); err != nil {
+				fmt.Fprintln(stderr, "warning: espanso not found in PATH, skipping reload")
+				return exitOK
+			}
+			running, err := espansoRunning()
+			if err != nil {
+				fmt.Fprintln(stderr, "warning: could not check espanso status, skipping reload:", err)
+				return exitOK
+			}
+			if !running {
+				fmt.Fprintln(stdout, "espanso is not running, skipping reload")
+				return exitOK
+			}
+		}
+		if err := runReload(reloadCmd); errors.Is(err, errReloadNotFound) {
+			fmt.Fprintln(stderr, "warning:", err, "(skipping reload)")
+			return exitOK
+		} else if err != nil {
+			fmt.Fprintln(stderr, "warning: reloading espanso failed:", err)
+			return exitOK
+		}
+		if !flags.quiet {
+			fmt.Fprintln(stdout, "Reloaded espanso")
+		}
 	}
-	fmt.Printf("Appended %d trigger(s) to %s\n", len(triggers), filePath)
+	return exitOK
 }
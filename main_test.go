@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestBuildYAMLSnippetSingle(t *testing.T) {
-	got := buildYAMLSnippet([]string{":one"}, "Hello")
+	got := buildYAMLSnippet([]string{":one"}, "Hello", SnippetOptions{})
 	want := "\n  - trigger: \":one\"\n    replace: \"Hello\"\n"
 	if got != want {
 		// Show a readable diff hint
@@ -17,8 +22,52 @@ func TestBuildYAMLSnippetSingle(t *testing.T) {
 	}
 }
 
+func TestBuildYAMLSnippetPropagateCase(t *testing.T) {
+	got := buildYAMLSnippet([]string{":kev"}, "Kevin", SnippetOptions{PropagateCase: true})
+	want := "\n  - trigger: \":kev\"\n    propagate_case: true\n    replace: \"Kevin\"\n"
+	if got != want {
+		t.Errorf("propagate_case YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+	if _, err := parseMatches([]byte("matches:" + got)); err != nil {
+		t.Errorf("snippet should parse: %v", err)
+	}
+}
+
+func TestBuildYAMLSnippetWord(t *testing.T) {
+	got := buildYAMLSnippet([]string{":a", ":b"}, "Hi", SnippetOptions{Word: true, PropagateCase: true})
+	want := "\n  - triggers: [\":a\", \":b\"]\n    propagate_case: true\n    word: true\n    replace: \"Hi\"\n"
+	if got != want {
+		t.Errorf("word YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+}
+
+func TestBuildYAMLSnippetLabel(t *testing.T) {
+	got := buildYAMLSnippet([]string{":sig"}, "Best", SnippetOptions{Label: `Email "sign-off"`, Word: true})
+	want := "\n  - trigger: \":sig\"\n    label: \"Email \\\"sign-off\\\"\"\n    word: true\n    replace: \"Best\"\n"
+	if got != want {
+		t.Errorf("label YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+	if got := buildYAMLSnippet([]string{":sig"}, "Best", SnippetOptions{Label: ""}); strings.Contains(got, "label") {
+		t.Errorf("an empty label should be omitted, got %q", got)
+	}
+}
+
+func TestBuildYAMLSnippetRegex(t *testing.T) {
+	got := buildYAMLSnippet([]string{`:date\((\d+)\)`}, "on {{0}}", SnippetOptions{Regex: true})
+	want := "\n  - regex: \":date\\\\((\\\\d+)\\\\)\"\n    replace: \"on {{0}}\"\n"
+	if got != want {
+		t.Errorf("regex YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+	var doc struct {
+		Matches []struct{ Regex string } `yaml:"matches"`
+	}
+	if err := yaml.Unmarshal([]byte("matches:"+got), &doc); err != nil || doc.Matches[0].Regex != `:date\((\d+)\)` {
+		t.Errorf("pattern should round-trip, got %+v (err=%v)", doc, err)
+	}
+}
+
 func TestBuildYAMLSnippetMultiple(t *testing.T) {
-	got := buildYAMLSnippet([]string{":a", ":b"}, "Hi")
+	got := buildYAMLSnippet([]string{":a", ":b"}, "Hi", SnippetOptions{})
 	want := "\n  - triggers: [\":a\", \":b\"]\n    replace: \"Hi\"\n"
 	if got != want {
 		t.Errorf("multi triggers YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
@@ -27,7 +76,7 @@ func TestBuildYAMLSnippetMultiple(t *testing.T) {
 
 func TestBuildYAMLSnippetMultiline(t *testing.T) {
 	multilineContent := "{quiz-task}\n    background: |\n        #f5f6f7\n    header: |\n\n    content: |\n\n        <content goes here>\n{/quiz-task}"
-	got := buildYAMLSnippet([]string{":cms-callout"}, multilineContent)
+	got := buildYAMLSnippet([]string{":cms-callout"}, multilineContent, SnippetOptions{})
 	want := "\n  - trigger: \":cms-callout\"\n    replace: |\n      {quiz-task}\n          background: |\n              #f5f6f7\n          header: |\n      \n          content: |\n      \n              <content goes here>\n      {/quiz-task}\n"
 	if got != want {
 		t.Errorf("multiline YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
@@ -36,13 +85,292 @@ func TestBuildYAMLSnippetMultiline(t *testing.T) {
 
 func TestBuildYAMLSnippetMultilineWithEmptyLines(t *testing.T) {
 	multilineContent := "line1\n\nline3\n"
-	got := buildYAMLSnippet([]string{":test"}, multilineContent)
+	got := buildYAMLSnippet([]string{":test"}, multilineContent, SnippetOptions{})
 	want := "\n  - trigger: \":test\"\n    replace: |\n      line1\n      \n      line3\n      \n"
 	if got != want {
 		t.Errorf("multiline with empty lines YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
 	}
 }
 
+func TestBuildYAMLSnippetInjectVarsDisabled(t *testing.T) {
+	opts := SnippetOptions{
+		Vars: []Var{
+			{Name: "today", Type: "date", Params: map[string]string{"format": "%Y-%m-%d"}},
+			{Name: "greeting", Type: "echo", Params: map[string]string{"echo": "Hi {{today}}"}},
+		},
+		DisableInjectVars: true,
+	}
+	got := buildYAMLSnippet([]string{":hi"}, "{{greeting}}", opts)
+	want := "\n  - trigger: \":hi\"\n    replace: \"{{greeting}}\"\n    vars:\n" +
+		"      - name: \"today\"\n        type: date\n        params:\n          format: \"%Y-%m-%d\"\n        inject_vars: false\n" +
+		"      - name: \"greeting\"\n        type: echo\n        params:\n          echo: \"Hi {{today}}\"\n        inject_vars: false\n"
+	if got != want {
+		t.Errorf("inject_vars YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+}
+
+func TestBuildYAMLSnippetInjectVarsOmittedByDefault(t *testing.T) {
+	opts := SnippetOptions{Vars: []Var{{Name: "today", Type: "date", Params: map[string]string{"format": "%F"}}}}
+	got := buildYAMLSnippet([]string{":d"}, "{{today}}", opts)
+	if strings.Contains(got, "inject_vars") {
+		t.Errorf("inject_vars should be omitted unless requested, got %q", got)
+	}
+}
+
+func TestParseTriggers(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: ":a :b", want: []string{":a", ":b"}},
+		{line: "  :a   :b  ", want: []string{":a", ":b"}},
+		{line: `":foo " :bar`, want: []string{":foo ", ":bar"}},
+		{line: `" :lead"`, want: []string{" :lead"}},
+		{line: "", want: nil},
+	}
+	for _, tt := range tests {
+		got := parseTriggers(tt.line)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTriggers(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestBuildYAMLSnippetTrailingSpaceTrigger(t *testing.T) {
+	got := buildYAMLSnippet([]string{":foo "}, "Foo", SnippetOptions{})
+	want := "\n  - trigger: \":foo \"\n    replace: \"Foo\"\n"
+	if got != want {
+		t.Errorf("trailing-space trigger YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+	matches, err := parseMatches([]byte("matches:" + got))
+	if err != nil || len(matches) != 1 || matches[0].Triggers[0] != ":foo " {
+		t.Errorf("trailing space not preserved by YAML: %+v err=%v", matches, err)
+	}
+}
+
+func TestTransformTriggerCase(t *testing.T) {
+	in := []string{":Sig", ":ADDR", ":mixed-Case"}
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{"", in},
+		{"none", in},
+		{"lower", []string{":sig", ":addr", ":mixed-case"}},
+		{"upper", []string{":SIG", ":ADDR", ":MIXED-CASE"}},
+	}
+	for _, tt := range tests {
+		got, err := transformTriggerCase(in, tt.mode)
+		if err != nil {
+			t.Fatalf("mode %q: unexpected error: %v", tt.mode, err)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("mode %q: got %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+	if _, err := transformTriggerCase(in, "title"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestApplyTriggerPrefix(t *testing.T) {
+	in := []string{"sig", ":addr", "::x", "a:b"}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", in},
+		{":", []string{":sig", ":addr", "::x", ":a:b"}},
+		{";;", []string{";;sig", ";;:addr", ";;::x", ";;a:b"}},
+	}
+	for _, tt := range tests {
+		got := applyTriggerPrefix(in, tt.prefix)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("prefix %q: got %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+	if in[0] != "sig" {
+		t.Error("input slice was modified")
+	}
+}
+
+func TestParseVarFlag(t *testing.T) {
+	v, err := parseVarFlag("now=date", "%d/%m/%Y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "now" || v.Type != "date" || v.Params["format"] != "%d/%m/%Y" {
+		t.Errorf("unexpected date var: %+v", v)
+	}
+	if v, err := parseVarFlag(" clip = clipboard ", ""); err != nil || v.Name != "clip" || v.Type != "clipboard" || v.Params != nil {
+		t.Errorf("unexpected clipboard var: %+v, %v", v, err)
+	}
+	for _, bad := range []string{"now", "=date", "now=", "two words=date", "now=shell"} {
+		if _, err := parseVarFlag(bad, ""); err == nil {
+			t.Errorf("parseVarFlag(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestBuildYAMLSnippet_DateVar(t *testing.T) {
+	v, _ := parseVarFlag("now=date", "")
+	got := buildYAMLSnippet([]string{":today"}, "Today is {{now}}", SnippetOptions{Vars: []Var{v}})
+	want := "\n  - trigger: \":today\"\n" +
+		"    replace: \"Today is {{now}}\"\n" +
+		"    vars:\n" +
+		"      - name: \"now\"\n" +
+		"        type: date\n" +
+		"        params:\n" +
+		"          format: \"%Y-%m-%d\"\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseModeChoice(t *testing.T) {
+	tests := []struct {
+		in, def, want string
+	}{
+		{"1", "eof", multilineModeMessaging},
+		{"2", "messaging", multilineModeEOF},
+		{"eof", "messaging", multilineModeEOF},
+		{" Messaging ", "eof", multilineModeMessaging},
+		{"", "eof", multilineModeEOF},
+	}
+	for _, tt := range tests {
+		got, err := parseModeChoice(tt.in, tt.def)
+		if err != nil {
+			t.Fatalf("parseModeChoice(%q) error: %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("parseModeChoice(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"3", "0", "paste"} {
+		if _, err := parseModeChoice(bad, "eof"); err == nil {
+			t.Errorf("parseModeChoice(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestAskMultilineMode(t *testing.T) {
+	var out bytes.Buffer
+	got, err := askMultilineMode(bufio.NewReader(strings.NewReader("2\n")), &out, multilineModeMessaging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != multilineModeEOF {
+		t.Errorf("got %q, want %q", got, multilineModeEOF)
+	}
+	if !strings.Contains(out.String(), "1) messaging") || !strings.Contains(out.String(), "[messaging]") {
+		t.Errorf("unexpected prompt output: %q", out.String())
+	}
+}
+
+func TestBuildYAMLSnippetSortTriggers(t *testing.T) {
+	triggers := []string{":b", ":c", ":a"}
+
+	got := buildYAMLSnippet(triggers, "x", SnippetOptions{SortTriggers: true})
+	want := "\n  - triggers: [\":a\", \":b\", \":c\"]\n    replace: \"x\"\n"
+	if got != want {
+		t.Errorf("sorted: got %q, want %q", got, want)
+	}
+	if strings.Join(triggers, " ") != ":b :c :a" {
+		t.Errorf("caller's slice was reordered: %v", triggers)
+	}
+
+	got = buildYAMLSnippet(triggers, "x", SnippetOptions{})
+	want = "\n  - triggers: [\":b\", \":c\", \":a\"]\n    replace: \"x\"\n"
+	if got != want {
+		t.Errorf("unsorted: got %q, want %q", got, want)
+	}
+}
+
+func TestDateVar(t *testing.T) {
+	tests := []struct {
+		format, def, want string
+	}{
+		{"", "", defaultDateFormat},
+		{"", "%d/%m/%Y", "%d/%m/%Y"},
+		{"%H:%M", "%d/%m/%Y", "%H:%M"},
+	}
+	for _, tt := range tests {
+		v := dateVar("now", tt.format, tt.def)
+		if v.Type != "date" || v.Name != "now" || v.Params["format"] != tt.want {
+			t.Errorf("dateVar(%q, %q) = %+v, want format %q", tt.format, tt.def, v, tt.want)
+		}
+	}
+}
+
+func TestBuildYAMLSnippetExtraKeys(t *testing.T) {
+	opts := SnippetOptions{Extra: []Extra{
+		{Key: "word", Value: "true"},
+		{Key: "hotkey", Value: "ALT+SHIFT+X"},
+		{Key: "label", Value: `say "hi": #1`},
+		{Key: "priority", Value: "10"},
+	}}
+	got := buildYAMLSnippet([]string{":hi"}, "Hello", opts)
+	want := "\n  - trigger: \":hi\"\n    replace: \"Hello\"\n" +
+		"    word: true\n    hotkey: \"ALT+SHIFT+X\"\n    label: \"say \\\"hi\\\": #1\"\n    priority: 10\n"
+	if got != want {
+		t.Errorf("extra keys mismatch\nGot:\n%s\nWant:\n%s", got, want)
+	}
+	var doc struct {
+		Matches []map[string]any `yaml:"matches"`
+	}
+	if err := yaml.Unmarshal([]byte("matches:"+got), &doc); err != nil {
+		t.Fatal(err)
+	}
+	m := doc.Matches[0]
+	if m["word"] != true || m["label"] != `say "hi": #1` || m["priority"] != 10 {
+		t.Errorf("unexpected decoded match: %v", m)
+	}
+}
+
+func TestParseExtra(t *testing.T) {
+	e, err := parseExtra("propagate_case=true")
+	if err != nil || e.Key != "propagate_case" || e.Value != "true" {
+		t.Errorf("got %+v, %v", e, err)
+	}
+	if e, err := parseExtra("label=a=b"); err != nil || e.Value != "a=b" {
+		t.Errorf("value with '=': got %+v, %v", e, err)
+	}
+	for _, bad := range []string{"noequals", "=x", "Bad-Key=x", "replace=x", "trigger=:a", "label=a\nb"} {
+		if _, err := parseExtra(bad); err == nil {
+			t.Errorf("parseExtra(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestNeedsBlockScalar(t *testing.T) {
+	risky := []string{"replace: foo", "- item", "# not a comment", "key:", "a: b", "&anchor", "*alias", "see #1 here", "|pipe"}
+	for _, s := range risky {
+		if !needsBlockScalar(s) {
+			t.Errorf("needsBlockScalar(%q) = false, want true", s)
+		}
+	}
+	safe := []string{"Hello", "Best, Kev", "http://example.com", "ratio 3:2", "", "  - indented", "{{greeting}}"}
+	for _, s := range safe {
+		if needsBlockScalar(s) {
+			t.Errorf("needsBlockScalar(%q) = true, want false", s)
+		}
+	}
+}
+
+func TestBuildYAMLSnippetRiskySingleLine(t *testing.T) {
+	for _, replace := range []string{"replace: foo", "- item", "# hash", "a: b #c"} {
+		got := buildYAMLSnippet([]string{":r"}, replace, SnippetOptions{})
+		want := "\n  - trigger: \":r\"\n    replace: |-\n      " + replace + "\n"
+		if got != want {
+			t.Errorf("risky YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+		}
+		matches, err := parseMatches([]byte("matches:" + got))
+		if err != nil || len(matches) != 1 || matches[0].Replace != replace {
+			t.Errorf("replace %q did not round-trip: %+v err=%v", replace, matches, err)
+		}
+	}
+}
+
 func TestPromptMultilineMode(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -78,6 +406,44 @@ func TestPromptMultilineMode(t *testing.T) {
 	}
 }
 
+func TestPromptMultilineNumbered(t *testing.T) {
+	tests := []struct {
+		mode  string
+		input string
+	}{
+		{mode: multilineModeMessaging, input: "one\ntwo\nthree\n\n"},
+		{mode: multilineModeEOF, input: "one\ntwo\nthree\nEOF\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := promptMultiline(bufio.NewReader(strings.NewReader(tt.input)), &out, "replace? ", tt.mode, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "one\ntwo\nthree" {
+				t.Errorf("captured text should exclude prompts, got %q", got)
+			}
+			for _, p := range []string{"  1> ", "  2> ", "  3> "} {
+				if !strings.Contains(out.String(), p) {
+					t.Errorf("output missing prompt %q: %q", p, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestPromptMultilineUnnumbered(t *testing.T) {
+	var out bytes.Buffer
+	_, err := promptMultiline(bufio.NewReader(strings.NewReader("a\n\n")), &out, "replace? ", multilineModeMessaging, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "1> ") {
+		t.Errorf("line numbers shown without --numbered-input: %q", out.String())
+	}
+}
+
 func TestExpandHome(t *testing.T) {
 	// Skip on systems without a home dir (very rare in normal Go CI)
 	home, err := os.UserHomeDir()
@@ -122,9 +488,13 @@ func TestEnsureFileWithHeader_CreatesFileWithHeader(t *testing.T) {
 	tdir := t.TempDir()
 	p := filepath.Join(tdir, "nested", "cliesp.yml")
 
-	if err := ensureFileWithHeader(p); err != nil {
+	created, err := ensureFileWithHeader(p, true)
+	if err != nil {
 		t.Fatalf("ensureFileWithHeader error: %v", err)
 	}
+	if !created {
+		t.Errorf("expected created=true for a new file")
+	}
 	b, err := os.ReadFile(p)
 	if err != nil {
 		t.Fatalf("reading created file: %v", err)
@@ -138,6 +508,26 @@ func TestEnsureFileWithHeader_CreatesFileWithHeader(t *testing.T) {
 	}
 }
 
+func TestEnsureFileWithHeader_MissingDirWithoutCreate(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "deep", "nested", "cliesp.yml")
+	created, err := ensureFileWithHeader(p, false)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected a missing-directory error, got %v", err)
+	}
+	if created {
+		t.Error("nothing should be created")
+	}
+	if _, err := os.Stat(filepath.Dir(filepath.Dir(p))); !os.IsNotExist(err) {
+		t.Errorf("no directories should be created, stat err=%v", err)
+	}
+
+	// An existing directory is fine either way.
+	p = filepath.Join(t.TempDir(), "cliesp.yml")
+	if created, err := ensureFileWithHeader(p, false); err != nil || !created {
+		t.Errorf("existing dir: created=%v err=%v", created, err)
+	}
+}
+
 func TestEnsureFileWithHeader_DoesNotOverwrite(t *testing.T) {
 	tdir := t.TempDir()
 	p := filepath.Join(tdir, "cliesp.yml")
@@ -148,9 +538,13 @@ func TestEnsureFileWithHeader_DoesNotOverwrite(t *testing.T) {
 	if err := os.WriteFile(p, []byte(orig), 0o644); err != nil {
 		t.Fatalf("seed file: %v", err)
 	}
-	if err := ensureFileWithHeader(p); err != nil {
+	created, err := ensureFileWithHeader(p, true)
+	if err != nil {
 		t.Fatalf("ensureFileWithHeader error: %v", err)
 	}
+	if created {
+		t.Errorf("expected created=false for an existing file")
+	}
 	b, err := os.ReadFile(p)
 	if err != nil {
 		t.Fatalf("read after ensure: %v", err)
@@ -166,3 +560,42 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+func TestConfirmDestructive(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		auto  bool
+		want  bool
+	}{
+		{"confirm", "y\n", false, true},
+		{"confirm yes", "YES\n", false, true},
+		{"deny", "n\n", false, false},
+		{"default no", "\n", false, false},
+		{"eof", "", false, false},
+		{"auto yes", "", true, true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		got, err := confirmDestructive(bufio.NewReader(strings.NewReader(tt.input)), &out, "remove 2 matches from cliesp.yml", tt.auto)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.auto && out.Len() != 0 {
+			t.Errorf("%s: auto-yes should not prompt, got %q", tt.name, out.String())
+		}
+		if !tt.auto && !strings.Contains(out.String(), "remove 2 matches") {
+			t.Errorf("%s: prompt should show what will change, got %q", tt.name, out.String())
+		}
+	}
+}
+
+func TestBuildYAMLSnippetSortTriggersIgnoresCase(t *testing.T) {
+	got := buildYAMLSnippet([]string{":Zed", ":bob"}, "x", SnippetOptions{SortTriggers: true})
+	if want := "\n  - triggers: [\":bob\", \":Zed\"]\n    replace: \"x\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Match is a parsed espanso match entry. Line is the 1-based line in the
+// source file where the entry starts.
+type Match struct {
+	Triggers []string
+	Replace  string
+	Label    string
+	Vars     []Var
+	// Word and PropagateCase mirror espanso's `word` and `propagate_case`.
+	Word          bool
+	PropagateCase bool
+	Line          int
+	// ID comes from a `# cliesp-id:` comment directly above the entry.
+	ID string
+	// Regex is the pattern of a `regex:` entry, which has no triggers.
+	Regex string
+}
+
+// AllTriggers returns the match's triggers followed by its regex pattern,
+// if any: everything listings, lookups and duplicate checks match against.
+func (m Match) AllTriggers() []string {
+	if m.Regex == "" {
+		return m.Triggers
+	}
+	return append(append([]string(nil), m.Triggers...), m.Regex)
+}
+
+// rawMatch mirrors the keys cliesp reads from a match entry. Both `trigger`
+// and `triggers` forms are accepted, as well as `regex`.
+type rawMatch struct {
+	Trigger       string   `yaml:"trigger"`
+	Triggers      []string `yaml:"triggers"`
+	Regex         string   `yaml:"regex"`
+	Replace       string   `yaml:"replace"`
+	Label         string   `yaml:"label"`
+	Vars          []rawVar `yaml:"vars"`
+	Word          bool     `yaml:"word"`
+	PropagateCase bool     `yaml:"propagate_case"`
+}
+
+// rawVar is a var entry; params are kept as scalars rendered to strings.
+type rawVar struct {
+	Name   string         `yaml:"name"`
+	Type   string         `yaml:"type"`
+	Params map[string]any `yaml:"params"`
+}
+
+// parseMatchFile reads and parses the match file at path.
+func parseMatchFile(path string) ([]Match, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseMatches(b)
+}
+
+// parseMatches parses the `matches:` list of an espanso match file. A file
+// with only the header (or no matches key at all) yields no matches.
+func parseMatches(content []byte) ([]Match, error) {
+	var doc struct {
+		Matches []yaml.Node `yaml:"matches"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	matches := make([]Match, 0, len(doc.Matches))
+	for _, n := range doc.Matches {
+		var r rawMatch
+		if err := n.Decode(&r); err != nil {
+			return nil, err
+		}
+		m := Match{
+			Replace:       r.Replace,
+			Label:         r.Label,
+			Word:          r.Word,
+			PropagateCase: r.PropagateCase,
+			Line:          n.Line,
+			ID:            idFromComment(n.HeadComment),
+			Regex:         r.Regex,
+		}
+		for _, v := range r.Vars {
+			mv := Var{Name: v.Name, Type: v.Type}
+			if len(v.Params) > 0 {
+				mv.Params = make(map[string]string, len(v.Params))
+				for k, p := range v.Params {
+					mv.Params[k] = fmt.Sprint(p)
+				}
+			}
+			m.Vars = append(m.Vars, mv)
+		}
+		if r.Trigger != "" {
+			m.Triggers = append(m.Triggers, r.Trigger)
+		}
+		m.Triggers = append(m.Triggers, r.Triggers...)
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// checkAnchor returns an error unless the match file at path defines the YAML
+// anchor name (as in `&name`).
+func checkAnchor(path, name string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return err
+	}
+	if !hasAnchor(&root, name) {
+		return fmt.Errorf("anchor %q is not defined in %s", name, path)
+	}
+	return nil
+}
+
+// hasAnchor reports whether n or any of its descendants carries anchor name.
+func hasAnchor(n *yaml.Node, name string) bool {
+	if n.Anchor == name {
+		return true
+	}
+	for _, c := range n.Content {
+		if hasAnchor(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadExistingTriggers returns the set of triggers already defined in the
+// match file at path. A missing file has no triggers.
+func loadExistingTriggers(path string) (map[string]bool, error) {
+	matches, err := parseMatchFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for _, m := range matches {
+		for _, t := range m.AllTriggers() {
+			existing[t] = true
+		}
+	}
+	return existing, nil
+}
+
+// lastMatchTriggers returns the set of triggers of the match just before
+// where at puts a new entry in the file at path. A missing or empty file,
+// or a section that doesn't exist yet, has none.
+func lastMatchTriggers(path string, at insertPoint) (map[string]bool, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	before, err := contentBefore(b, at)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := parseMatches(before)
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[string]bool)
+	if len(matches) > 0 {
+		for _, t := range matches[len(matches)-1].AllTriggers() {
+			last[t] = true
+		}
+	}
+	return last, nil
+}
+
+// matchSnippet re-emits a parsed match the way cliesp would write it. Block
+// scalars are read back with a trailing newline, which buildYAMLSnippet adds
+// itself, so one is dropped from multiline replacements.
+func matchSnippet(m Match) string {
+	replace := m.Replace
+	if trimmed := strings.TrimSuffix(replace, "\n"); strings.Contains(trimmed, "\n") {
+		replace = trimmed
+	}
+	triggers := m.Triggers
+	if m.Regex != "" {
+		triggers = []string{m.Regex}
+	}
+	return buildYAMLSnippet(triggers, replace, SnippetOptions{
+		Regex:         m.Regex != "",
+		Vars:          m.Vars,
+		ID:            m.ID,
+		Label:         m.Label,
+		Word:          m.Word,
+		PropagateCase: m.PropagateCase,
+	})
+}
+
+// printMatchYAML returns the YAML for the match defining trigger in the file
+// at path, as cliesp would emit it.
+func printMatchYAML(path, trigger string) (string, error) {
+	matches, err := parseMatchFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, m := range matches {
+		for _, t := range m.AllTriggers() {
+			if t == trigger {
+				return matchSnippet(m), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("trigger %q not found in %s", trigger, path)
+}
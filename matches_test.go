@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeMatchFixture writes content to a temp match file and returns its path.
+func writeMatchFixture(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "cliesp.yml")
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestBuildYAMLSnippetExtends(t *testing.T) {
+	got := buildYAMLSnippet([]string{":x"}, "X", SnippetOptions{Extends: "base"})
+	want := "\n  - trigger: \":x\"\n    <<: *base\n    replace: \"X\"\n"
+	if got != want {
+		t.Errorf("extends YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+}
+
+func TestCheckAnchor(t *testing.T) {
+	content := "matches:\n  - &base\n    trigger: \":base\"\n    replace: \"b\"\n    word: true\n"
+	p := writeMatchFixture(t, content)
+	if err := checkAnchor(p, "base"); err != nil {
+		t.Fatalf("expected anchor to be found: %v", err)
+	}
+	err := checkAnchor(p, "missing")
+	if err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Fatalf("expected missing-anchor error, got %v", err)
+	}
+
+	// The merged entry must still be valid YAML for the whole file.
+	entry := buildYAMLSnippet([]string{":x"}, "X", SnippetOptions{Extends: "base"})
+	if err := validateMatchYAML([]byte(content + entry)); err != nil {
+		t.Errorf("file with merge key should validate: %v", err)
+	}
+}
+
+func TestPrintMatchYAML_MatchesBuilder(t *testing.T) {
+	single := buildYAMLSnippet([]string{":sig"}, "Best, Kev", SnippetOptions{})
+	multi := buildYAMLSnippet([]string{":addr", ":home"}, "1 Main St\nSpringfield", SnippetOptions{})
+	withVars := buildYAMLSnippet([]string{":today"}, "{{d}}", SnippetOptions{Vars: []Var{{Name: "d", Type: "date", Params: map[string]string{"format": "%F"}}}})
+	p := writeMatchFixture(t, matchFileHeader+single+multi+withVars)
+
+	tests := []struct {
+		trigger string
+		want    string
+	}{
+		{":sig", single},
+		{":home", multi},
+		{":today", withVars},
+	}
+	for _, tt := range tests {
+		got, err := printMatchYAML(p, tt.trigger)
+		if err != nil {
+			t.Fatalf("printMatchYAML(%q) error: %v", tt.trigger, err)
+		}
+		if got != tt.want {
+			t.Errorf("printMatchYAML(%q) mismatch\nGot:\n%q\nWant:\n%q", tt.trigger, got, tt.want)
+		}
+	}
+
+	if _, err := printMatchYAML(p, ":missing"); err == nil {
+		t.Error("expected error for unknown trigger")
+	}
+}
+
+func TestLoadExistingTriggers(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"empty file", "", nil},
+		{"header only", matchFileHeader, nil},
+		{"matches", matchFileHeader + "  - trigger: \":a\"\n    replace: \"A\"\n  - triggers: [\":b\", \":c\"]\n    replace: \"B\"\n", []string{":a", ":b", ":c"}},
+	}
+	for _, tt := range tests {
+		got, err := loadExistingTriggers(writeMatchFixture(t, tt.content))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		for _, tr := range tt.want {
+			if !got[tr] {
+				t.Errorf("%s: missing %q", tt.name, tr)
+			}
+		}
+	}
+
+	got, err := loadExistingTriggers(filepath.Join(t.TempDir(), "missing.yml"))
+	if err != nil || len(got) != 0 {
+		t.Errorf("missing file: got %v, %v", got, err)
+	}
+}
+
+func TestParseMatchFile_Options(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+
+		buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{Word: true})+
+		buildYAMLSnippet([]string{":sig", ":signature"}, "Best,\nKev", SnippetOptions{Label: "Signature", PropagateCase: true}))
+	matches, err := parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || !matches[0].Word || matches[1].Label != "Signature" || !matches[1].PropagateCase {
+		t.Fatalf("fields not parsed: %+v", matches)
+	}
+	if matches[1].Replace != "Best,\nKev\n" {
+		t.Errorf("Replace = %q", matches[1].Replace)
+	}
+}
+
+func TestParseMatches_Regex(t *testing.T) {
+	content := matchFileHeader + buildYAMLSnippet([]string{`:greet\((?P<person>.*)\)`}, "Hi {{person}}!", SnippetOptions{Regex: true})
+	matches, err := parseMatches([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Regex != `:greet\((?P<person>.*)\)` || len(matches[0].Triggers) != 0 {
+		t.Fatalf("unexpected matches: %+v", matches)
+	}
+	if got := matches[0].AllTriggers(); len(got) != 1 || got[0] != matches[0].Regex {
+		t.Errorf("AllTriggers() = %q", got)
+	}
+	if got := matchSnippet(matches[0]); got != buildYAMLSnippet([]string{matches[0].Regex}, "Hi {{person}}!", SnippetOptions{Regex: true}) {
+		t.Errorf("matchSnippet should re-emit the regex, got %q", got)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// menuAction is an entry of the interactive menu.
+type menuAction int
+
+const (
+	menuAddMatch menuAction = iota + 1
+	menuList
+	menuOpenFile
+	menuOpenDir
+	menuEditConfig
+	menuQuit
+)
+
+// menuItems lists the menu entries in display order.
+var menuItems = []struct {
+	action menuAction
+	label  string
+}{
+	{menuAddMatch, "Add a match"},
+	{menuList, "List matches"},
+	{menuOpenFile, "Open match file"},
+	{menuOpenDir, "Open match directory"},
+	{menuEditConfig, "Edit config"},
+	{menuQuit, "Quit"},
+}
+
+// parseMenuChoice maps the user's answer (an item number) to its action.
+func parseMenuChoice(s string) (menuAction, error) {
+	s = strings.TrimSpace(s)
+	for i, item := range menuItems {
+		if s == fmt.Sprint(i+1) {
+			return item.action, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid choice %q", s)
+}
+
+// runMenu shows the numbered menu and asks until a valid choice is made.
+// Running out of input selects quit.
+func runMenu(in *bufio.Reader, out io.Writer) (menuAction, error) {
+	for {
+		for i, item := range menuItems {
+			fmt.Fprintf(out, "  %d) %s\n", i+1, item.label)
+		}
+		ans, err := prompt(in, out, "choose an option: ")
+		if errors.Is(err, io.EOF) {
+			return menuQuit, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		action, err := parseMenuChoice(ans)
+		if err == nil {
+			return action, nil
+		}
+		fmt.Fprintln(out, err)
+	}
+}
+
+// printTriggers writes the triggers of each match, one entry per line,
+// followed by a preview of the replacement cut to width characters. Only
+// the first line of a multiline replacement is shown.
+func printTriggers(w io.Writer, matches []Match, width int) {
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "no matches found")
+		return
+	}
+	for _, m := range matches {
+		triggers := strings.Join(m.AllTriggers(), ", ")
+		preview := truncatePreview(firstLine(m.Replace), width)
+		if preview == "" {
+			fmt.Fprintln(w, triggers)
+			continue
+		}
+		fmt.Fprintf(w, "%s  %s\n", triggers, preview)
+	}
+}
+
+// searchHit is a match found by --search, with the lines of its
+// replacement that contain the query.
+type searchHit struct {
+	Match Match
+	Lines []string
+}
+
+// searchMatches returns the matches whose triggers or replacement contain
+// query, ignoring case.
+func searchMatches(matches []Match, query string) []searchHit {
+	re := queryPattern(query)
+	var hits []searchHit
+	for _, m := range matches {
+		var lines []string
+		for _, line := range strings.Split(strings.Trim(m.Replace, "\n"), "\n") {
+			if re.MatchString(line) {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 || re.MatchString(strings.Join(m.AllTriggers(), "\n")) {
+			hits = append(hits, searchHit{Match: m, Lines: lines})
+		}
+	}
+	return hits
+}
+
+// queryPattern matches query literally, ignoring case.
+func queryPattern(query string) *regexp.Regexp {
+	return regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+}
+
+// printSearchHits writes the triggers of each hit followed by its matching
+// replacement lines, indented, with every occurrence of query wrapped in
+// brackets.
+func printSearchHits(w io.Writer, hits []searchHit, query string) {
+	if len(hits) == 0 {
+		fmt.Fprintln(w, "no matches")
+		return
+	}
+	re := queryPattern(query)
+	mark := func(s string) string { return re.ReplaceAllString(s, "[$0]") }
+	for _, h := range hits {
+		fmt.Fprintln(w, mark(strings.Join(h.Match.AllTriggers(), ", ")))
+		for _, line := range h.Lines {
+			fmt.Fprintf(w, "    %s\n", mark(strings.TrimSpace(line)))
+		}
+	}
+}
+
+// firstLine returns the first non-blank line of s, followed by an ellipsis
+// when more lines come after it.
+func firstLine(s string) string {
+	s = strings.Trim(s, "\n")
+	first, rest, found := strings.Cut(s, "\n")
+	if found && strings.TrimSpace(rest) != "" {
+		return strings.TrimRight(first, " \t") + "…"
+	}
+	return first
+}
+
+// contextBanner is the one-line summary --show-context prints before the
+// prompts: where the match goes, the multiline input mode and how many
+// match files the directory holds.
+func contextBanner(path, mode string, files int) string {
+	return fmt.Sprintf("Adding to %s (mode: %s, %d match file(s) in %s)", path, mode, files, filepath.Dir(path))
+}
+
+// defaultPreviewWidth is used when neither config nor the terminal says
+// how wide replace previews may be.
+const defaultPreviewWidth = 60
+
+// resolvePreviewWidth picks the replace preview width: the flag, then the
+// config, then $COLUMNS when the shell exports it, then defaultPreviewWidth.
+func resolvePreviewWidth(flagWidth, cfgWidth int) int {
+	switch {
+	case flagWidth > 0:
+		return flagWidth
+	case cfgWidth > 0:
+		return cfgWidth
+	}
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+	return defaultPreviewWidth
+}
+
+// truncatePreview flattens s onto one line and cuts it to at most width
+// runes, ending in an ellipsis when anything was dropped.
+func truncatePreview(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// configFilePath returns the cliesp config file, preferring an existing
+// settings.{yaml|yml|toml|json} and falling back to settings.yaml.
+func configFilePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	for _, ext := range []string{"yaml", "yml", "toml", "json"} {
+		p := filepath.Join(dir, "settings."+ext)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return filepath.Join(dir, "settings.yaml"), nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseMenuChoice(t *testing.T) {
+	tests := []struct {
+		in   string
+		want menuAction
+	}{
+		{"1", menuAddMatch},
+		{"2", menuList},
+		{" 3 ", menuOpenFile},
+		{"4", menuOpenDir},
+		{"5", menuEditConfig},
+		{"6", menuQuit},
+	}
+	for _, tt := range tests {
+		got, err := parseMenuChoice(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseMenuChoice(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "0", "7", "add"} {
+		if _, err := parseMenuChoice(bad); err == nil {
+			t.Errorf("parseMenuChoice(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRunMenu_RetriesUntilValid(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("9\n2\n"))
+	var out bytes.Buffer
+	got, err := runMenu(in, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != menuList {
+		t.Errorf("got %v want %v", got, menuList)
+	}
+	if !strings.Contains(out.String(), `invalid choice "9"`) {
+		t.Errorf("expected invalid choice message, got %q", out.String())
+	}
+}
+
+func TestRunMenu_EOFQuits(t *testing.T) {
+	got, err := runMenu(bufio.NewReader(strings.NewReader("")), &bytes.Buffer{})
+	if err != nil || got != menuQuit {
+		t.Errorf("got %v, %v; want quit", got, err)
+	}
+}
+
+func TestRun_MenuList(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+"  - trigger: \":a\"\n    replace: \"A\"\n")
+	code, stdout, stderr := runCLI(t, "2\n", "--menu", "--matchFile", p)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stdout, ":a  A\n") {
+		t.Errorf("expected listed trigger, got %q", stdout)
+	}
+}
+
+func TestRun_InteractiveConfigOnlyWithoutArgs(t *testing.T) {
+	t.Setenv("CLIESP_INTERACTIVE", "true")
+	p := writeMatchFixture(t, matchFileHeader)
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "-t", ":a", "-r", "A")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if strings.Contains(stdout, "choose an option") || !strings.Contains(stdout, "Appended 1 trigger(s)") {
+		t.Errorf("a run with flags should skip the menu, got %q", stdout)
+	}
+
+	code, stdout, stderr = runCLI(t, "6\n")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stdout, "choose an option") {
+		t.Errorf("a bare run should show the menu, got %q", stdout)
+	}
+}
+
+func TestTruncatePreview(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"Hello", 10, "Hello"},
+		{"Hello", 5, "Hello"},
+		{"Hello world", 6, "Hello…"},
+		{"Hello", 1, "…"},
+		{"Hello", 0, "Hello"},
+		{"line one\n  line two", 40, "line one line two"},
+		{"héllo wörld", 4, "hél…"},
+		{"日本語のテキスト", 5, "日本語の…"},
+	}
+	for _, tt := range tests {
+		if got := truncatePreview(tt.in, tt.width); got != tt.want {
+			t.Errorf("truncatePreview(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePreviewWidth(t *testing.T) {
+	t.Setenv("COLUMNS", "")
+	if got := resolvePreviewWidth(0, 0); got != defaultPreviewWidth {
+		t.Errorf("default = %d, want %d", got, defaultPreviewWidth)
+	}
+	if got := resolvePreviewWidth(0, 30); got != 30 {
+		t.Errorf("config width = %d, want 30", got)
+	}
+	if got := resolvePreviewWidth(12, 30); got != 12 {
+		t.Errorf("flag width = %d, want 12", got)
+	}
+	t.Setenv("COLUMNS", "100")
+	if got := resolvePreviewWidth(0, 0); got != 100 {
+		t.Errorf("terminal width = %d, want 100", got)
+	}
+}
+
+func TestPrintTriggers_TruncatesPreview(t *testing.T) {
+	var buf bytes.Buffer
+	printTriggers(&buf, []Match{{Triggers: []string{":a", ":b"}, Replace: "a long replacement"}}, 8)
+	if got, want := buf.String(), ":a, :b  a long …\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFirstLine(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"single", "single"},
+		{"Best,\nKev\n", "Best,…"},
+		{"\nafter blank\n", "after blank"},
+		{"trailing\n\n", "trailing"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := firstLine(tt.in); got != tt.want {
+			t.Errorf("firstLine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRun_List(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+
+		buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})+
+		buildYAMLSnippet([]string{":sig", ":signature"}, "Best,\nKev", SnippetOptions{}))
+	code, stdout, stderr := runCLI(t, "", "-l", "--matchFile", p)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if want := ":a  A\n:sig, :signature  Best,…\n"; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+
+	empty := writeMatchFixture(t, matchFileHeader)
+	code, stdout, _ = runCLI(t, "", "--list", "--matchFile", empty)
+	if code != 0 || stdout != "no matches found\n" {
+		t.Errorf("empty file: exit=%d stdout=%q", code, stdout)
+	}
+}
+
+func TestRun_Count(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+
+		buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})+
+		buildYAMLSnippet([]string{":sig", ":signature"}, "Best,\nKev", SnippetOptions{}))
+	code, stdout, stderr := runCLI(t, "", "--count", "--matchFile", p)
+	if code != 0 || stdout != "2\n" {
+		t.Fatalf("exit=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+
+	empty := writeMatchFixture(t, matchFileHeader)
+	code, stdout, _ = runCLI(t, "", "--count", "--matchFile", empty)
+	if code != 0 || stdout != "0\n" {
+		t.Errorf("header-only file: exit=%d stdout=%q", code, stdout)
+	}
+}
+
+func TestRun_Search(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+
+		buildYAMLSnippet([]string{":addr"}, "221B Baker Street", SnippetOptions{})+
+		buildYAMLSnippet([]string{":sig", ":signature"}, "Best regards,\nKev\nsent from BAKER st", SnippetOptions{})+
+		buildYAMLSnippet([]string{":bake"}, "cake", SnippetOptions{})+
+		buildYAMLSnippet([]string{":x"}, "unrelated", SnippetOptions{}))
+	code, stdout, stderr := runCLI(t, "", "--search", "baker", "--matchFile", p)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	want := ":addr\n    221B [Baker] Street\n" +
+		":sig, :signature\n    sent from [BAKER] st\n"
+	if stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+
+	code, stdout, _ = runCLI(t, "", "--search", ":BAK", "--matchFile", p)
+	if code != 0 || stdout != "[:bak]e\n" {
+		t.Errorf("trigger search: exit=%d stdout=%q", code, stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "", "--search", "nothing here", "--matchFile", p)
+	if code != 0 || stdout != "no matches\n" {
+		t.Errorf("no hits: exit=%d stdout=%q", code, stdout)
+	}
+}
+
+func TestContextBanner(t *testing.T) {
+	got := contextBanner("/m/cliesp.yml", "eof", 3)
+	if want := "Adding to /m/cliesp.yml (mode: eof, 3 match file(s) in /m)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRun_ShowContext(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, stdout, stderr := runCLI(t, ":a\nA\n\n", "--matchFile", p, "--show-context")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	want := contextBanner(p, defaultMultilineMode, 1) + "\ntriggers?"
+	if !strings.HasPrefix(stdout, want) {
+		t.Errorf("expected the banner before the prompt, got %q", stdout)
+	}
+}
+
+// fakeTerminal makes run treat stdin as a terminal for the duration of a
+// test.
+func fakeTerminal(t *testing.T) {
+	t.Helper()
+	orig := isTerminal
+	isTerminal = func(io.Reader) bool { return true }
+	t.Cleanup(func() { isTerminal = orig })
+}
+
+func TestRun_InteractiveAddAsksOptions(t *testing.T) {
+	fakeTerminal(t)
+	p := writeMatchFixture(t, matchFileHeader)
+	code, stdout, stderr := runCLI(t, ":sig\nBest\n\nSignature\ny\ny\n", "--matchFile", p)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	for _, q := range []string{"label? (optional): ", "propagate case? [y/N] ", "whole words only? [y/N] "} {
+		if !strings.Contains(stdout, q) {
+			t.Errorf("expected prompt %q, got %q", q, stdout)
+		}
+	}
+	matches, err := parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Label != "Signature" || !matches[0].PropagateCase || !matches[0].Word {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+func TestRun_InteractiveAddSkipsAnsweredOptions(t *testing.T) {
+	fakeTerminal(t)
+	p := writeMatchFixture(t, matchFileHeader)
+	code, stdout, stderr := runCLI(t, ":sig\nBest\n\n", "--matchFile", p, "--label", "Sig", "-p", "-w")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if strings.Contains(stdout, "label?") || strings.Contains(stdout, "propagate case?") || strings.Contains(stdout, "whole words only?") {
+		t.Errorf("flags should skip their prompts, got %q", stdout)
+	}
+}
+
+func TestRun_PipedAddSkipsOptions(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, stdout, stderr := runCLI(t, ":sig\nBest\n\n", "--matchFile", p)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if strings.Contains(stdout, "label?") {
+		t.Errorf("stdin that isn't a terminal should skip the optional prompts, got %q", stdout)
+	}
+}
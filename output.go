@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// outputOnlyRequested reports whether args ask for --output-only. It is
+// checked before the config is loaded, since that mode must not read any
+// files or need a home directory.
+func outputOnlyRequested(args []string) bool {
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		name, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if name != "output-only" || !strings.HasPrefix(a, "-") {
+			continue
+		}
+		if !hasVal {
+			return true
+		}
+		on, err := strconv.ParseBool(val)
+		return err == nil && on
+	}
+	return false
+}
+
+// runOutputOnly builds a match from the flags (prompting on stderr for
+// whatever they leave out) and writes only the snippet, to stdout or the
+// --emit-to file. It goes through the same builder as an append, but no
+// match file is resolved, created or appended to.
+func runOutputOnly(flags cliFlags, ef entryFlags, cfg AppConfig, args []string, in *bufio.Reader, stdout, stderr io.Writer) int {
+	triggers, err := readTriggers(in, stderr, flags, args)
+	if err != nil {
+		fmt.Fprintln(stderr, "error reading triggers:", err)
+		return exitError
+	}
+	triggers, code := prepareTriggers(stderr, triggers, flags, cfg)
+	if code != exitOK {
+		return code
+	}
+	mode := cfg.MultilineMode
+	if mode == "" {
+		mode = defaultMultilineMode
+	}
+	replaceStr, vars, err := readReplace(in, stderr, stderr, flags, cfg, mode)
+	if err != nil {
+		fmt.Fprintln(stderr, "error reading replace string:", err)
+		return exitError
+	}
+	built, code := buildEntry(stderr, triggers, replaceStr, vars, flags, ef, cfg)
+	if code != exitOK {
+		return code
+	}
+
+	snippet := strings.TrimPrefix(built.Text, "\n")
+	if flags.emitTo == "" || flags.emitTo == "-" {
+		fmt.Fprint(stdout, snippet)
+		return exitOK
+	}
+	target, err := expandHome(flags.emitTo)
+	if err != nil {
+		fmt.Fprintln(stderr, "error resolving --emit-to:", err)
+		return exitPath
+	}
+	if err := os.WriteFile(target, []byte(snippet), 0o644); err != nil {
+		fmt.Fprintln(stderr, "error writing snippet:", err)
+		return exitWrite
+	}
+	return exitOK
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// buildProposedContent returns the content the match file at path would have
+// after appending entry. A missing file is treated as a fresh file containing
+// only the managed header. The result is validated before it is returned, so
+// callers never see content that espanso would fail to load.
+func buildProposedContent(path, entry string) (string, error) {
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		existing = []byte(matchFileHeader)
+	} else if err != nil {
+		return "", err
+	}
+	content := string(existing) + entry
+	if err := validateMatchYAML([]byte(content)); err != nil {
+		return "", fmt.Errorf("resulting file would be invalid: %w", err)
+	}
+	return content, nil
+}
+
+// previewFile writes the full proposed content of the match file to w without
+// touching the file on disk. The proposed content is run through the lint
+// checks, and any findings (such as a duplicate trigger) are returned so the
+// caller can report them alongside the preview.
+func previewFile(w io.Writer, path, entry string) ([]LintIssue, error) {
+	content, err := buildProposedContent(path, entry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		return nil, err
+	}
+	return lintContent([]byte(content)), nil
+}
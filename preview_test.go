@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreviewFile_PrintsFullContent(t *testing.T) {
+	tdir := t.TempDir()
+	p := filepath.Join(tdir, "cliesp.yml")
+	orig := "matches:\n  - trigger: \":a\"\n    replace: \"A\"\n"
+	if err := os.WriteFile(p, []byte(orig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	entry := buildYAMLSnippet([]string{":b"}, "B", SnippetOptions{})
+
+	var buf bytes.Buffer
+	issues, err := previewFile(&buf, p, entry)
+	if err != nil {
+		t.Fatalf("previewFile error: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("expected no findings, got %v", issues)
+	}
+	want := "matches:\n  - trigger: \":a\"\n    replace: \"A\"\n\n  - trigger: \":b\"\n    replace: \"B\"\n"
+	if buf.String() != want {
+		t.Errorf("preview mismatch\nGot:\n%q\nWant:\n%q", buf.String(), want)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != orig {
+		t.Errorf("preview must not modify the file, got %q", string(b))
+	}
+}
+
+func TestPreviewFile_MissingFileUsesHeader(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "new.yml")
+	entry := buildYAMLSnippet([]string{":x"}, "X", SnippetOptions{})
+
+	var buf bytes.Buffer
+	if _, err := previewFile(&buf, p, entry); err != nil {
+		t.Fatalf("previewFile error: %v", err)
+	}
+	if buf.String() != matchFileHeader+entry {
+		t.Errorf("unexpected preview for new file: %q", buf.String())
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("preview must not create the file, stat err=%v", err)
+	}
+}
+
+func TestRun_PreviewReportsDuplicateWithoutWriting(t *testing.T) {
+	orig := matchFileHeader + "  - trigger: \":sig\"\n    replace: \"Best\"\n"
+	p := writeMatchFixture(t, orig)
+
+	code, stdout, stderr := runCLI(t, ":sig\nAgain\n\n", "--matchFile", p, "--preview-file")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stdout, "replace: \"Again\"") {
+		t.Errorf("expected the previewed entry on stdout, got %q", stdout)
+	}
+	if !strings.Contains(stderr, `warning: `+p+`: line 11: duplicate trigger ":sig" (first defined on line 8)`) {
+		t.Errorf("expected a duplicate warning, got %q", stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != orig {
+		t.Errorf("preview must not modify the file, got %q", string(b))
+	}
+}
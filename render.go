@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// tokenPattern matches an espanso var reference such as {{today}}.
+var tokenPattern = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// cursorMarker is where espanso leaves the cursor after an expansion.
+const cursorMarker = "$|$"
+
+// countPlaceholders returns how many {{name}} var references and $|$ cursor
+// markers s contains. A reference written with escaped braces (\{\{name\}\}
+// or \{{name}}) is literal text and isn't counted.
+func countPlaceholders(s string) (vars int, cursors int) {
+	for _, loc := range tokenPattern.FindAllStringIndex(s, -1) {
+		if loc[0] > 0 && s[loc[0]-1] == '\\' {
+			continue
+		}
+		vars++
+	}
+	return vars, strings.Count(s, cursorMarker)
+}
+
+// renderReplace expands the {{name}} tokens of replace that refer to date or
+// echo vars, as espanso would at expansion time. Any other token is left as
+// it is. The result is only for previews and is never written.
+func renderReplace(replace string, vars []Var, now time.Time) string {
+	byName := make(map[string]Var, len(vars))
+	for _, v := range vars {
+		byName[v.Name] = v
+	}
+	return tokenPattern.ReplaceAllStringFunc(replace, func(tok string) string {
+		v, ok := byName[tokenPattern.FindStringSubmatch(tok)[1]]
+		if !ok {
+			return tok
+		}
+		switch v.Type {
+		case "date":
+			return strftime(now, v.Params["format"])
+		case "echo":
+			return v.Params["echo"]
+		}
+		return tok
+	})
+}
+
+// strftimeVerbs maps the strftime directives espanso date vars commonly use
+// to Go layouts.
+var strftimeVerbs = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'b': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'F': "2006-01-02", 'T': "15:04:05", 'R': "15:04", 'z': "-0700", 'Z': "MST",
+}
+
+// strftime formats t using a strftime-style format. Unsupported directives
+// are copied through unchanged.
+func strftime(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch d := format[i]; d {
+		case '%':
+			b.WriteByte('%')
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		default:
+			if layout, ok := strftimeVerbs[d]; ok {
+				b.WriteString(t.Format(layout))
+			} else {
+				b.WriteByte('%')
+				b.WriteByte(d)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderReplace(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 14, 7, 9, 0, time.UTC)
+	vars := []Var{
+		{Name: "today", Type: "date", Params: map[string]string{"format": "%Y-%m-%d %H:%M"}},
+		{Name: "name", Type: "echo", Params: map[string]string{"echo": "Kev"}},
+		{Name: "cmd", Type: "shell", Params: map[string]string{"cmd": "whoami"}},
+	}
+	got := renderReplace("Hi {{name}}, it's {{ today }}. {{cmd}} {{clipboard}}", vars, now)
+	want := "Hi Kev, it's 2024-03-05 14:07. {{cmd}} {{clipboard}}"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStrftime(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 14, 7, 9, 0, time.UTC)
+	tests := map[string]string{
+		"%d/%m/%Y":      "05/03/2024",
+		"%A, %B %e":     "Tuesday, March  5",
+		"%I:%M %p":      "02:07 PM",
+		"%F %T":         "2024-03-05 14:07:09",
+		"day %j, 100%%": "day 065, 100%",
+		"%Q stays":      "%Q stays",
+	}
+	for format, want := range tests {
+		if got := strftime(now, format); got != want {
+			t.Errorf("strftime(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestCountPlaceholders(t *testing.T) {
+	tests := []struct {
+		in            string
+		vars, cursors int
+	}{
+		{"plain text", 0, 0},
+		{"Hi {{name}}, it's {{ today }}", 2, 0},
+		{"{{a}}{{a}}$|$", 2, 1},
+		{"<div>$|$</div> and $|$", 0, 2},
+		{`literal \{\{name\}\} and \{{other}}`, 0, 0},
+		{`\{{escaped}} but {{real}}`, 1, 0},
+		{"{{ not a var", 0, 0},
+		{"{single}", 0, 0},
+	}
+	for _, tt := range tests {
+		vars, cursors := countPlaceholders(tt.in)
+		if vars != tt.vars || cursors != tt.cursors {
+			t.Errorf("countPlaceholders(%q) = %d, %d; want %d, %d", tt.in, vars, cursors, tt.vars, tt.cursors)
+		}
+	}
+}
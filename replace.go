@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dedent removes the leading whitespace common to every non-blank line of s.
+// Blank lines don't count towards the common indent; they are stripped of up
+// to that much whitespace so no trailing spaces are left behind.
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+	prefix := ""
+	found := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if !found {
+			prefix, found = indent, true
+			continue
+		}
+		prefix = commonPrefix(prefix, indent)
+	}
+	if prefix == "" {
+		return s
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = line[min(len(line), len(prefix)):]
+			continue
+		}
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commonPrefix returns the longest prefix shared by a and b.
+func commonPrefix(a, b string) string {
+	n := min(len(a), len(b))
+	i := 0
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+// quoteReplacer maps curly and other typographic quotes to ASCII ones.
+var quoteReplacer = strings.NewReplacer(
+	"\u2018", "'", // ‘ left single
+	"\u2019", "'", // ’ right single (and apostrophe)
+	"\u201A", "'", // ‚ single low-9
+	"\u201B", "'", // ‛ single high-reversed-9
+	"\uFF07", "'", // ＇ fullwidth apostrophe
+	"\u201C", `"`, // “ left double
+	"\u201D", `"`, // ” right double
+	"\u201E", `"`, // „ double low-9
+	"\u201F", `"`, // ‟ double high-reversed-9
+	"\uFF02", `"`, // ＂ fullwidth quotation mark
+)
+
+// straightenQuotes replaces smart quotes in s with straight ASCII quotes.
+// Dashes and other punctuation are left alone.
+func straightenQuotes(s string) string {
+	return quoteReplacer.Replace(s)
+}
+
+// wrapCode fences s in a Markdown code block, tagged with lang when given.
+// The result always spans several lines, so it is emitted as a block scalar.
+func wrapCode(s, lang string) string {
+	return "```" + lang + "\n" + strings.TrimRight(s, "\n") + "\n```"
+}
+
+// indentLines prefixes every non-blank line of s with n spaces. Blank lines
+// are left empty so no trailing whitespace is introduced.
+func indentLines(s string, n int) string {
+	if n <= 0 {
+		return s
+	}
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textFilters are the named filters --transform can chain together.
+var textFilters = map[string]func(string) string{
+	"trim":        strings.TrimSpace,
+	"collapse-ws": func(s string) string { return strings.Join(strings.Fields(s), " ") },
+	"lower":       strings.ToLower,
+	"upper":       strings.ToUpper,
+	"dedent":      dedent,
+}
+
+// parseTransforms resolves a comma-separated list of filter names, such as
+// "trim,collapse-ws", into a pipeline. Unknown names are an error.
+func parseTransforms(spec string) ([]func(string) string, error) {
+	var pipeline []func(string) string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		f, ok := textFilters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q (available: %s)", name, strings.Join(sortedKeys(textFilters), ", "))
+		}
+		pipeline = append(pipeline, f)
+	}
+	return pipeline, nil
+}
+
+// applyTransforms runs s through each filter of pipeline in order.
+func applyTransforms(s string, pipeline []func(string) string) string {
+	for _, f := range pipeline {
+		s = f(s)
+	}
+	return s
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDedent(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "uniform indent",
+			in:   "    func f() {}\n    func g() {}",
+			want: "func f() {}\nfunc g() {}",
+		},
+		{
+			name: "non-uniform indent keeps relative indentation",
+			in:   "    if x {\n        y()\n    }",
+			want: "if x {\n    y()\n}",
+		},
+		{
+			name: "blank lines ignored when computing indent",
+			in:   "    a\n\n  \n    b",
+			want: "a\n\n\nb",
+		},
+		{
+			name: "no common indent",
+			in:   "a\n  b",
+			want: "a\n  b",
+		},
+		{
+			name: "tabs",
+			in:   "\t\tx\n\t\t\ty",
+			want: "x\n\ty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dedent(tt.in); got != tt.want {
+				t.Errorf("dedent(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapCode(t *testing.T) {
+	if got, want := wrapCode("fmt.Println(1)", "go"), "```go\nfmt.Println(1)\n```"; got != want {
+		t.Errorf("wrapCode with lang = %q, want %q", got, want)
+	}
+	if got, want := wrapCode("ls -la\n", ""), "```\nls -la\n```"; got != want {
+		t.Errorf("wrapCode without lang = %q, want %q", got, want)
+	}
+}
+
+func TestWrapCode_EmitsBlockScalar(t *testing.T) {
+	got := buildYAMLSnippet([]string{":code"}, wrapCode("x := 1", "go"), SnippetOptions{})
+	want := "\n  - trigger: \":code\"\n    replace: |\n      ```go\n      x := 1\n      ```\n"
+	if got != want {
+		t.Errorf("fenced YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+}
+
+func TestTransformPipeline(t *testing.T) {
+	pipeline, err := parseTransforms("trim, collapse-ws,upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := applyTransforms("  hello \n   big\tworld  ", pipeline)
+	if want := "HELLO BIG WORLD"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Order matters: lower runs after upper here.
+	pipeline, _ = parseTransforms("upper,lower")
+	if got := applyTransforms("MiXed", pipeline); got != "mixed" {
+		t.Errorf("got %q, want %q", got, "mixed")
+	}
+
+	if pipeline, err := parseTransforms(""); err != nil || len(pipeline) != 0 {
+		t.Errorf("empty spec: got %d filters, err=%v", len(pipeline), err)
+	}
+}
+
+func TestParseTransforms_UnknownFilter(t *testing.T) {
+	_, err := parseTransforms("trim,shout")
+	if err == nil {
+		t.Fatal("expected an error for an unknown filter")
+	}
+	if !strings.Contains(err.Error(), `"shout"`) || !strings.Contains(err.Error(), "collapse-ws") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestIndentLines(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"a\nb", 0, "a\nb"},
+		{"a\nb", 2, "  a\n  b"},
+		{"a\n\n  b", 4, "    a\n\n      b"},
+		{"single", 3, "   single"},
+	}
+	for _, tt := range tests {
+		if got := indentLines(tt.in, tt.n); got != tt.want {
+			t.Errorf("indentLines(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestIndentedReplaceRoundTrips(t *testing.T) {
+	for _, n := range []int{2, 4, 8} {
+		replace := indentLines("func main() {\n\n\tfmt.Println()\n}", n)
+		entry := buildYAMLSnippet([]string{":go"}, replace, SnippetOptions{})
+		if !strings.Contains(entry, "replace: |2\n") {
+			t.Errorf("indent %d: expected an indentation indicator, got %q", n, entry)
+		}
+		matches, err := parseMatches([]byte("matches:" + entry))
+		if err != nil {
+			t.Fatalf("indent %d: %v", n, err)
+		}
+		if got := strings.TrimSuffix(matches[0].Replace, "\n"); got != replace {
+			t.Errorf("indent %d: round trip = %q, want %q", n, got, replace)
+		}
+	}
+}
+
+func TestStraightenQuotes(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"“Hello,” she said. ‘It’s fine.’", `"Hello," she said. 'It's fine.'`},
+		{"„Guten Tag‟ and ‚hier‛", `"Guten Tag" and 'hier'`},
+		{"＂wide＂ ＇too＇", `"wide" 'too'`},
+		{"en – and em — dashes stay", "en – and em — dashes stay"},
+		{`already "straight" 'quotes'`, `already "straight" 'quotes'`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := straightenQuotes(tt.in); got != tt.want {
+			t.Errorf("straightenQuotes(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,818 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runCLI drives run with scripted stdin and returns the exit code and
+// captured output. HOME points at a temp dir so no user config or state is
+// read or written. The append confirmation is turned off so scripts don't
+// need to answer it; TestRun_ConfirmAppend covers it.
+func runCLI(t *testing.T, input string, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("CLIESP_CONFIRM_APPEND", "false")
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(input), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_AppendsMatch(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+
+	code, stdout, stderr := runCLI(t, ":sig :signature\nBest,\nKev\n\n", "--matchFile", p)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stdout, "Appended 2 trigger(s)") {
+		t.Errorf("unexpected stdout: %q", stdout)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := matchFileHeader + "\n  - triggers: [\":sig\", \":signature\"]\n    replace: |\n      Best,\n      Kev\n"
+	if string(b) != want {
+		t.Errorf("file content mismatch\nGot:\n%q\nWant:\n%q", string(b), want)
+	}
+}
+
+func TestRun_RejectsDuplicateTrigger(t *testing.T) {
+	orig := matchFileHeader + "  - trigger: \":sig\"\n    replace: \"Best\"\n"
+	p := writeMatchFixture(t, orig)
+
+	code, _, stderr := runCLI(t, ":sig\nAgain\n\n", "--matchFile", p)
+	if code == 0 {
+		t.Fatal("expected non-zero exit for a duplicate trigger")
+	}
+	if !strings.Contains(stderr, `":sig" already exists`) {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != orig {
+		t.Errorf("file should be unchanged, got %q", string(b))
+	}
+}
+
+func TestRun_ForceAppendsDuplicateTrigger(t *testing.T) {
+	orig := matchFileHeader + "  - trigger: \":sig\"\n    replace: \"Best\"\n"
+	p := writeMatchFixture(t, orig)
+
+	code, _, stderr := runCLI(t, ":sig\nAgain\n\n", "--matchFile", p, "--force")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(b), `trigger: ":sig"`) != 2 {
+		t.Errorf("expected the duplicate to be appended, got:\n%s", b)
+	}
+}
+
+func TestRun_IdenticalAppendIsNoOp(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	args := []string{"--matchFile", p, "-t", ":sig", "-r", "Best"}
+	if code, _, stderr := runCLI(t, "", args...); code != 0 {
+		t.Fatalf("first append: exit=%d stderr=%q", code, stderr)
+	}
+	want, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCLI(t, "", args...)
+	if code != 0 {
+		t.Fatalf("second append: exit=%d stderr=%q", code, stderr)
+	}
+	if !strings.Contains(stdout, "identical entry is already in place") {
+		t.Errorf("expected a note about the identical entry, got %q", stdout)
+	}
+	got, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("file should be unchanged\nGot:\n%s\nWant:\n%s", got, want)
+	}
+}
+
+func TestRun_IdenticalCheckLooksAtInsertPoint(t *testing.T) {
+	sig := buildYAMLSnippet([]string{":sig"}, "Best", SnippetOptions{})
+	x := buildYAMLSnippet([]string{":x"}, "X", SnippetOptions{})
+
+	// Re-running an append into a section in the middle is a no-op.
+	content := matchFileHeader + sectionComment("Email") + "\n" + sig + "\n" + sectionComment("Other") + "\n" + x
+	p := writeMatchFixture(t, content)
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "--group", "Email", "-t", ":sig", "-r", "Best")
+	if code != 0 || !strings.Contains(stdout, "identical entry is already in place") {
+		t.Errorf("grouped re-run: exit=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+	if got, _ := os.ReadFile(p); string(got) != content {
+		t.Errorf("grouped re-run changed the file: %q", got)
+	}
+
+	// The same entry at the end of the file but in another section is a
+	// duplicate trigger, not a re-run.
+	content = matchFileHeader + sectionComment("Email") + "\n" + x + "\n" + sectionComment("Other") + "\n" + sig
+	p = writeMatchFixture(t, content)
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--group", "Email", "-t", ":sig", "-r", "Best")
+	if code != exitValidation || !strings.Contains(stderr, `trigger ":sig" already exists`) {
+		t.Errorf("duplicate in another section: exit=%d stderr=%q", code, stderr)
+	}
+
+	// With --append-sorted the entry before the sorted position counts.
+	content = matchFileHeader + sig + x
+	p = writeMatchFixture(t, content)
+	code, stdout, stderr = runCLI(t, "", "--matchFile", p, "--append-sorted", "-t", ":sig", "-r", "Best")
+	if code != 0 || !strings.Contains(stdout, "identical entry is already in place") {
+		t.Errorf("sorted re-run: exit=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+	if got, _ := os.ReadFile(p); string(got) != content {
+		t.Errorf("sorted re-run changed the file: %q", got)
+	}
+}
+
+func TestRun_EmptyFileHasNoDuplicates(t *testing.T) {
+	p := writeMatchFixture(t, "")
+	code, _, stderr := runCLI(t, ":sig\nBest\n\n", "--matchFile", p)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestRun_TriggerCaseBeforeDuplicateCheck(t *testing.T) {
+	orig := matchFileHeader + "  - trigger: \":sig\"\n    replace: \"Best\"\n"
+	p := writeMatchFixture(t, orig)
+
+	code, _, stderr := runCLI(t, ":SIG\nAgain\n\n", "--matchFile", p, "--trigger-case-transform", "lower")
+	if code == 0 {
+		t.Fatal("expected the lowercased trigger to be rejected as a duplicate")
+	}
+	if !strings.Contains(stderr, `":sig" already exists`) {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+}
+
+func TestRun_NewFileDeclined(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "new.yml")
+	code, _, _ := runCLI(t, ":x\nn\n", "--matchFile", p)
+	if code == 0 {
+		t.Fatal("expected non-zero exit when creation is declined")
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("declined file should be removed, stat err=%v", err)
+	}
+}
+
+func TestRun_RawTrigger(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, ":foo \nFoo\n\n", "--matchFile", p, "--raw-trigger")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `- trigger: ":foo "`) {
+		t.Errorf("trailing space lost: %q", string(b))
+	}
+}
+
+func TestRun_DefaultFlagsFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "new.yml")
+	t.Setenv("CLIESP_DEFAULT_FLAGS", "--yes --matchFile "+p)
+
+	// --yes from the defaults skips the new-file confirmation.
+	code, _, stderr := runCLI(t, ":x\nX\n\n")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if _, err := os.Stat(p); err != nil {
+		t.Fatalf("default --matchFile not applied: %v", err)
+	}
+
+	// An explicit flag overrides the configured default.
+	other := filepath.Join(dir, "other.yml")
+	code, _, stderr = runCLI(t, ":y\nY\n\n", "--matchFile", other)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(other)
+	if err != nil || !strings.Contains(string(b), `":y"`) {
+		t.Errorf("explicit --matchFile should win, content=%q err=%v", string(b), err)
+	}
+}
+
+func TestRun_ReplaceFromURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "from the web\n")
+	}))
+	defer srv.Close()
+	old := httpClient
+	httpClient = srv.Client()
+	defer func() { httpClient = old }()
+
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, ":web\n", "--matchFile", p, "--replace-from-url", srv.URL)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `replace: "from the web"`) {
+		t.Errorf("unexpected content %q", string(b))
+	}
+}
+
+func TestRun_FromHistory(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, ":a\nFirst reply\n\n", "--matchFile", p)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+
+	// runCLI gives each call a fresh HOME, so drive run directly to keep it.
+	var stdout, errOut bytes.Buffer
+	code = run([]string{"--matchFile", p, "--from-history"}, strings.NewReader(":b\n1\n"), &stdout, &errOut)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, errOut.String())
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "- trigger: \":b\"\n    replace: \"First reply\"") {
+		t.Errorf("history entry not reused, file:\n%s", b)
+	}
+}
+
+func TestRun_TimestampUsesConfiguredFormat(t *testing.T) {
+	t.Setenv("CLIESP_DEFAULT_DATE_FORMAT", "%d/%m/%Y")
+	for _, tt := range []struct {
+		arg, want string
+	}{
+		{"--timestamp", `format: "%d/%m/%Y"`},
+		{"--timestamp=%H:%M", `format: "%H:%M"`},
+	} {
+		p := writeMatchFixture(t, matchFileHeader)
+		code, _, stderr := runCLI(t, ":now\n", "--matchFile", p, tt.arg)
+		if code != 0 {
+			t.Fatalf("%s: exit=%d stderr=%q", tt.arg, code, stderr)
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), `replace: "{{timestamp}}"`) || !strings.Contains(string(b), tt.want) {
+			t.Errorf("%s: unexpected file:\n%s", tt.arg, b)
+		}
+	}
+}
+
+func TestRun_SafeRejectsPathOutsideMatchDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLIESP_MATCH_DIR", filepath.Join(dir, "match"))
+	outside := filepath.Join(dir, "match", "..", "outside.yml")
+
+	code, _, stderr := runCLI(t, ":a\nA\n\n", "--safe", "--yes", "--matchFile", outside)
+	if code == 0 {
+		t.Fatal("expected --safe to reject a path outside the match dir")
+	}
+	if !strings.Contains(stderr, "outside the match dir") {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.yml")); !os.IsNotExist(err) {
+		t.Errorf("nothing should be written, stat err=%v", err)
+	}
+
+	inside := filepath.Join(dir, "match", "cliesp.yml")
+	if code, _, stderr := runCLI(t, ":a\nA\n\n", "--safe", "--yes", "--matchFile", inside); code != 0 {
+		t.Errorf("in-dir path rejected: exit=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestRun_PreviewRender(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, stdout, stderr := runCLI(t, ":now\n", "--matchFile", p, "--timestamp=%Y", "--preview-file", "--render")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	want := "--- rendered replacement ---\n" + time.Now().Format("2006") + "\n"
+	if !strings.Contains(stdout, want) {
+		t.Errorf("expected rendered timestamp in %q", stdout)
+	}
+	if !strings.Contains(stdout, `replace: "{{timestamp}}"`) {
+		t.Errorf("the previewed file should keep the token, got %q", stdout)
+	}
+}
+
+func TestRun_DryRunRender(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "-t", ":now", "--timestamp=%Y", "--dry-run", "--render")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	snippet, rendered, ok := strings.Cut(stdout, "\n--- rendered replacement ---\n")
+	if !ok {
+		t.Fatalf("expected a rendered replacement, got %q", stdout)
+	}
+	if !strings.Contains(snippet, `replace: "{{timestamp}}"`) {
+		t.Errorf("the snippet should keep the token, got %q", snippet)
+	}
+	if want := time.Now().Format("2006") + "\n"; rendered != want {
+		t.Errorf("rendered %q, want %q", rendered, want)
+	}
+	if got, _ := os.ReadFile(p); string(got) != matchFileHeader {
+		t.Errorf("dry run changed the file: %q", got)
+	}
+}
+
+func TestRun_TouchCreatesHeaderOnly(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "work.yml")
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "--touch")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if strings.Contains(stdout, "?") {
+		t.Errorf("--touch should not prompt, got %q", stdout)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != matchFileHeader {
+		t.Errorf("expected only the header, got %q", string(b))
+	}
+	matches, err := parseMatches(b)
+	if err != nil || len(matches) != 0 {
+		t.Errorf("expected no matches, got %v (err=%v)", matches, err)
+	}
+
+	// Touching again leaves the file alone.
+	if code, stdout, _ := runCLI(t, "", "--matchFile", p, "--touch"); code != 0 || !strings.Contains(stdout, "already exists") {
+		t.Errorf("second touch: exit=%d stdout=%q", code, stdout)
+	}
+}
+
+func TestRun_RejectsInvalidUTF8(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, ":cafe\ncaf\xe9\n\n", "--matchFile", p)
+	if code != exitValidation {
+		t.Fatalf("exit=%d, want %d (stderr=%q)", code, exitValidation, stderr)
+	}
+	if !strings.Contains(stderr, "not valid UTF-8") {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+	if b, _ := os.ReadFile(p); string(b) != matchFileHeader {
+		t.Errorf("file should be unchanged, got %q", string(b))
+	}
+}
+
+func TestRun_NonInteractive(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "new.yml")
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "-t", ":sig", "--trigger", ":signature", "-r", "Best, Kev")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if strings.Contains(stdout, "?") {
+		t.Errorf("should not prompt, got %q", stdout)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(b), "- triggers: [\":sig\", \":signature\"]\n    replace: \"Best, Kev\"\n") {
+		t.Errorf("unexpected file:\n%s", b)
+	}
+}
+
+func TestRun_ReplaceFromStdin(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "line one\n\nline three\n", "--matchFile", p, "-t", ":piped", "--replace", "-")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "replace: |\n      line one\n      \n      line three\n") {
+		t.Errorf("piped replacement not used, file:\n%s", b)
+	}
+}
+
+func TestRun_LinesJoinedIntoBlockScalar(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "--trigger", ":x", "--line", "a", "--line", "b")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(b), "- trigger: \":x\"\n    replace: |\n      a\n      b\n") {
+		t.Errorf("unexpected file:\n%s", b)
+	}
+}
+
+func TestRun_LineConflictsWithReplace(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "-t", ":x", "-r", "a", "--line", "b")
+	if code != exitUsage {
+		t.Fatalf("exit=%d, want %d", code, exitUsage)
+	}
+	if !strings.Contains(stderr, "cannot be used together") {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+}
+
+func TestRun_OutputOnlyWithoutHome(t *testing.T) {
+	t.Setenv("HOME", "")
+	os.Unsetenv("HOME")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+	wd := t.TempDir()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--output-only", "-t", ":x", "--line", "a", "--line", "b", "-w"}, strings.NewReader(""), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr.String())
+	}
+	want := "  - trigger: \":x\"\n    word: true\n    replace: |\n      a\n      b\n"
+	if stdout.String() != want {
+		t.Errorf("got %q, want %q", stdout.String(), want)
+	}
+	target := filepath.Join(wd, "snippet.yml")
+	stdout.Reset()
+	code = run([]string{"--output-only", "--emit-to", target, "-t", ":y", "-r", "Y"}, strings.NewReader(""), &stdout, &stderr)
+	if code != 0 || stdout.Len() != 0 {
+		t.Fatalf("exit=%d stdout=%q stderr=%q", code, stdout.String(), stderr.String())
+	}
+	if b, err := os.ReadFile(target); err != nil || string(b) != "  - trigger: \":y\"\n    replace: \"Y\"\n" {
+		t.Errorf("emitted %q (err=%v)", b, err)
+	}
+}
+
+func TestRun_OutputOnlyMatchesDryRun(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+"  - &base\n    trigger: \":base\"\n    replace: \"base\"\n")
+	id := regexp.MustCompile(`# cliesp-id: \S+`)
+	for _, args := range [][]string{
+		{"-t", ":x", "-r", "{{d}}: fmt.Println(1)", "--code=go", "--indent", "2", "--var", "d=date", "--inject-vars=false", "--trigger-case-transform", "upper"},
+		{"-t", ":auto", "-r", "#!/bin/sh\necho hi", "--auto-code", "--label", "Auto", "-w"},
+		{"-x", "-t", `:a(\d+)`, "-r", "A", "--extends", "base"},
+		{"-t", ":now", "--timestamp=%Y", "--with-id", "--extra", "left_word=true"},
+	} {
+		code, want, stderr := runCLI(t, "", append([]string{"--matchFile", p, "--dry-run"}, args...)...)
+		if code != 0 {
+			t.Fatalf("%q --dry-run: exit=%d stderr=%q", args, code, stderr)
+		}
+		code, got, stderr := runCLI(t, "", append([]string{"--output-only"}, args...)...)
+		if code != 0 {
+			t.Fatalf("%q --output-only: exit=%d stderr=%q", args, code, stderr)
+		}
+		want = id.ReplaceAllString(strings.TrimPrefix(want, "\n"), "# cliesp-id: ID")
+		got = id.ReplaceAllString(got, "# cliesp-id: ID")
+		if got != want {
+			t.Errorf("%q: --output-only printed\n%s\nbut --dry-run printed\n%s", args, got, want)
+		}
+	}
+}
+
+func TestRun_DryRunMatchesWrittenBytes(t *testing.T) {
+	input := ":sig :signature\n  Best,\nKev\n\n"
+	dry := filepath.Join(t.TempDir(), "new.yml")
+	code, stdout, stderr := runCLI(t, input, "--matchFile", dry, "-n")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if _, err := os.Stat(dry); !os.IsNotExist(err) {
+		t.Errorf("--dry-run should not create the file, stat err=%v", err)
+	}
+
+	p := writeMatchFixture(t, matchFileHeader)
+	if code, _, stderr := runCLI(t, input, "--matchFile", p); code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Prompts come first on stdout; the snippet is everything after them.
+	if written := strings.TrimPrefix(string(b), matchFileHeader); !strings.HasSuffix(stdout, "to submit, single Enter for new line)\n"+written) {
+		t.Errorf("dry run printed %q, but %q was written", stdout, written)
+	}
+}
+
+func TestRun_DryRunReportsDuplicate(t *testing.T) {
+	orig := matchFileHeader + "  - trigger: \":sig\"\n    replace: \"Best\"\n"
+	p := writeMatchFixture(t, orig)
+
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "--dry-run", "-t", ":sig", "-r", "Again")
+	if code != 0 {
+		t.Fatalf("a dry run should not fail on findings: exit=%d stderr=%q", code, stderr)
+	}
+	if stdout != buildYAMLSnippet([]string{":sig"}, "Again", SnippetOptions{}) {
+		t.Errorf("stdout should be just the snippet, got %q", stdout)
+	}
+	if !strings.Contains(stderr, `warning: `+p+`: line 11: duplicate trigger ":sig" (first defined on line 8)`) {
+		t.Errorf("expected a duplicate warning, got %q", stderr)
+	}
+	if got, _ := os.ReadFile(p); string(got) != orig {
+		t.Errorf("dry run changed the file: %q", got)
+	}
+}
+
+func TestRun_InvalidTriggerRejected(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "new.yml")
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "-t", " ", "-r", "x")
+	if code != exitValidation || !strings.Contains(stderr, `trigger " " is empty or only whitespace`) {
+		t.Errorf("exit=%d stderr=%q", code, stderr)
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("no file should be created, stat err=%v", err)
+	}
+
+	if code, _, stderr := runCLI(t, "", "--matchFile", p, "-t", ":a\x01", "-r", "x", "--no-validate"); code != 0 {
+		t.Errorf("--no-validate: exit=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestRun_Regex(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "Hi\n\n", "--matchFile", p, "-x", `:greet(\w+)`)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `- regex: ":greet(\\w+)"`) {
+		t.Errorf("unexpected file:\n%s", b)
+	}
+
+	code, stdout, _ := runCLI(t, "", "--matchFile", p, "--list")
+	if code != 0 || stdout != ":greet(\\w+)  Hi\n" {
+		t.Errorf("--list should show the pattern: exit=%d stdout=%q", code, stdout)
+	}
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--force", "-t", ":other", "-r", "O")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	code, _, stderr = runCLI(t, "Again\n\n", "--matchFile", p, "-x", `:greet(\w+)`)
+	if code != exitValidation || !strings.Contains(stderr, "already exists") {
+		t.Errorf("duplicate pattern: exit=%d stderr=%q", code, stderr)
+	}
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--force", "--delete", `:greet(\w+)`)
+	if code != 0 {
+		t.Fatalf("--delete by pattern: exit=%d stderr=%q", code, stderr)
+	}
+	if code, stdout, _ = runCLI(t, "", "--matchFile", p, "--count"); stdout != "1\n" {
+		t.Errorf("expected only :other left, --count printed %q", stdout)
+	}
+
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--regex", "-r", "x", "(unclosed")
+	if code != exitValidation || !strings.Contains(stderr, "invalid regex") {
+		t.Errorf("bad pattern: exit=%d stderr=%q", code, stderr)
+	}
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--regex", "-r", "x", "a", "b")
+	if code != exitUsage || !strings.Contains(stderr, "single pattern") {
+		t.Errorf("two patterns: exit=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestRun_ConfirmAppend(t *testing.T) {
+	tests := []struct {
+		name, answer string
+		args         []string
+		appended     bool
+	}{
+		{"yes", "y\n", nil, true},
+		{"no", "n\n", nil, false},
+		{"eof", "", nil, false},
+		{"--yes skips", "", []string{"--yes"}, true},
+	}
+	for _, tt := range tests {
+		p := writeMatchFixture(t, matchFileHeader)
+		var stdout, stderr bytes.Buffer
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("XDG_STATE_HOME", "")
+		t.Setenv("CLIESP_CONFIRM_APPEND", "")
+		os.Unsetenv("CLIESP_CONFIRM_APPEND")
+		args := append([]string{"--matchFile", p}, tt.args...)
+		code := run(args, strings.NewReader(":a\nA\n\n"+tt.answer), &stdout, &stderr)
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(b), `":a"`); got != tt.appended {
+			t.Errorf("%s: appended=%v, want %v (exit=%d stderr=%q)", tt.name, got, tt.appended, code, stderr.String())
+		}
+		if (code == 0) != tt.appended {
+			t.Errorf("%s: exit=%d", tt.name, code)
+		}
+		if tt.args == nil && !strings.Contains(stdout.String(), "  - trigger: \":a\"\n    replace: \"A\"\nAppend this match to "+p+"? [y/N]") {
+			t.Errorf("%s: expected the snippet and question, got %q", tt.name, stdout.String())
+		}
+	}
+}
+
+func TestRun_ListEmpty(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+"\n  - trigger: \":x\"\n    replace: \"\"\n")
+	code, stdout, _ := runCLI(t, "", "--matchFile", p, "--list-empty")
+	if code != exitValidation {
+		t.Fatalf("exit=%d, want %d", code, exitValidation)
+	}
+	if !strings.Contains(stdout, ":x") {
+		t.Errorf("unexpected stdout: %q", stdout)
+	}
+
+	p = writeMatchFixture(t, matchFileHeader)
+	if code, _, stderr := runCLI(t, "", "--matchFile", p, "--list-empty"); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestRun_MatchFileWinsOverProfile(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, ":hi\nhello\n\n", "--matchFile", p, "--profile", "missing")
+	if code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestRun_UnknownProfile(t *testing.T) {
+	code, _, stderr := runCLI(t, ":hi\nhello\n\n", "-P", "missing")
+	if code != exitConfig {
+		t.Fatalf("exit=%d, want %d", code, exitConfig)
+	}
+	if !strings.Contains(stderr, `unknown profile "missing"`) {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+}
+
+func TestRun_ReplaceFromCommand(t *testing.T) {
+	fakeRunner(t, "built at 12:00\n", nil)
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, ":built\n", "--matchFile", p, "--replace-from-command", "date")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	matches, err := parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Replace != "built at 12:00" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+
+	fakeRunner(t, "", errors.New("exit status 2: boom"))
+	code, _, stderr = runCLI(t, ":x\n", "--matchFile", p, "--replace-from-command", "false")
+	if code != exitError || !strings.Contains(stderr, "false: exit status 2: boom") {
+		t.Errorf("failing command: exit=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestRun_TriggerPrefix(t *testing.T) {
+	t.Setenv("CLIESP_TRIGGER_PREFIX", ":")
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "sig :addr\nHi\n\n", "--matchFile", p)
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	matches, err := parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(matches[0].Triggers, " "); got != ":sig :addr" {
+		t.Errorf("triggers = %q", got)
+	}
+
+	// An explicit empty --prefix turns the configured one off.
+	code, _, stderr = runCLI(t, "plain\nHi\n\n", "--matchFile", p, "--prefix", "")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	matches, err = parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := matches[1].Triggers[0]; got != "plain" {
+		t.Errorf("trigger = %q, want plain", got)
+	}
+}
+
+func TestRun_VarFlag(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "-t", ":today", "-r", "{{now}} / {{clip}}", "--var", "now=date", "--var", "clip=clipboard")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if stderr != "" {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+	matches, err := parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vs := matches[0].Vars; len(vs) != 2 || vs[0].Type != "date" || vs[1].Type != "clipboard" {
+		t.Errorf("unexpected vars: %+v", vs)
+	}
+
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "-t", ":x", "-r", "no placeholder", "--var", "now=date")
+	if code != 0 || !strings.Contains(stderr, `doesn't use var "now"`) {
+		t.Errorf("unused var: exit=%d stderr=%q", code, stderr)
+	}
+	if code, _, _ := runCLI(t, "", "--matchFile", p, "-t", ":y", "-r", "y", "--var", "now=shell"); code != exitUsage {
+		t.Errorf("bad type: exit=%d, want %d", code, exitUsage)
+	}
+}
+
+func TestRun_StraightenQuotes(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "-t", ":q", "-r", "“It’s here”", "--straighten-quotes")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	matches, err := parseMatchFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := matches[0].Replace; got != `"It's here"` {
+		t.Errorf("replace = %q", got)
+	}
+}
+
+func TestRun_ReadsConfigFromXDGConfigHome(t *testing.T) {
+	matchDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(matchDir, "xdg.yml"), []byte(matchFileHeader+
+		buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	xdg := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(xdg, "cliesp"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	settings := fmt.Sprintf("match_dir: %q\nmatch_file: xdg.yml\n", matchDir)
+	if err := os.WriteFile(filepath.Join(xdg, "cliesp", "settings.yaml"), []byte(settings), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--count"}, strings.NewReader(""), &stdout, &stderr)
+	if code != 0 || stdout.String() != "1\n" {
+		t.Fatalf("exit=%d stdout=%q stderr=%q", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestRun_InjectVarsNeedsEquals(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "--inject-vars", "false", "-t", ":d", "-r", "{{d}}", "--var", "d=date")
+	if code != exitUsage || !strings.Contains(stderr, "--inject-vars=false") {
+		t.Errorf("exit=%d stderr=%q", code, stderr)
+	}
+	if got, _ := os.ReadFile(p); string(got) != matchFileHeader {
+		t.Errorf("file changed: %q", got)
+	}
+
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--word", "true", "-t", ":d", "-r", "D")
+	if code != exitUsage || !strings.Contains(stderr, "--word=true") {
+		t.Errorf("--word true: exit=%d stderr=%q", code, stderr)
+	}
+	if code, _, stderr := runCLI(t, "", "--matchFile", p, "--dry-run", "-r", "T", "-x", "true"); code != 0 {
+		t.Errorf("a --regex pattern of true: exit=%d stderr=%q", code, stderr)
+	}
+
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--inject-vars=false", "-t", ":d", "-r", "{{d}}", "--var", "d=date")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if got, _ := os.ReadFile(p); !strings.Contains(string(got), "inject_vars: false") {
+		t.Errorf("expected inject_vars: false, got %q", got)
+	}
+}
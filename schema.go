@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// envPrefix is the prefix the config loader adds to AppConfig env tags.
+const envPrefix = "CLIESP_"
+
+// FieldDoc describes one AppConfig setting as a user would write it.
+type FieldDoc struct {
+	Key     string // key in the config file
+	Type    string // Go type, e.g. string, int, map[string]string
+	Env     string // environment variable, empty if the field has none
+	Default string // value from defaultConfig, empty if unset
+}
+
+// describeConfig lists the AppConfig settings in declaration order, derived
+// from the struct tags so it stays in sync with the code.
+func describeConfig() []FieldDoc {
+	defaults := reflect.ValueOf(defaultConfig())
+	t := defaults.Type()
+	docs := make([]FieldDoc, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		doc := FieldDoc{Key: key, Type: f.Type.String()}
+		if env := f.Tag.Get("env"); env != "" {
+			doc.Env = envPrefix + env
+		}
+		if v := defaults.Field(i); !v.IsZero() {
+			doc.Default = fmt.Sprint(v.Interface())
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// printConfigSchema writes docs as an aligned table.
+func printConfigSchema(w io.Writer, docs []FieldDoc) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tTYPE\tENV\tDEFAULT")
+	for _, d := range docs {
+		env, def := d.Env, d.Default
+		if env == "" {
+			env = "-"
+		}
+		if def == "" {
+			def = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Key, d.Type, env, def)
+	}
+	tw.Flush()
+}
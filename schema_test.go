@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDescribeConfig(t *testing.T) {
+	byKey := map[string]FieldDoc{}
+	for _, d := range describeConfig() {
+		byKey[d.Key] = d
+	}
+
+	dir, ok := byKey["match_dir"]
+	if !ok {
+		t.Fatal("match_dir missing from schema")
+	}
+	if dir.Env != "CLIESP_MATCH_DIR" || dir.Type != "string" || dir.Default != defaultEspansoMatchDir {
+		t.Errorf("unexpected match_dir doc: %+v", dir)
+	}
+	if d := byKey["max_trigger_length"]; d.Env != "CLIESP_MAX_TRIGGER_LENGTH" || d.Type != "int" {
+		t.Errorf("unexpected max_trigger_length doc: %+v", d)
+	}
+	if d := byKey["ensure_final_newline"]; d.Default != "true" {
+		t.Errorf("ensure_final_newline default = %q, want true", d.Default)
+	}
+	if d, ok := byKey["routes"]; !ok || d.Env != "" {
+		t.Errorf("routes should be listed without an env var: %+v", d)
+	}
+}
+
+func TestPrintConfigSchema(t *testing.T) {
+	var buf bytes.Buffer
+	printConfigSchema(&buf, []FieldDoc{{Key: "routes", Type: "map[string]string"}})
+	out := buf.String()
+	if !strings.HasPrefix(out, "KEY") || !strings.Contains(out, "routes  map[string]string  -") {
+		t.Errorf("unexpected table:\n%s", out)
+	}
+}
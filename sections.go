@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// sectionPattern matches a section comment such as `  # === Email ===`.
+var sectionPattern = regexp.MustCompile(`^\s*#\s*===\s*(.*?)\s*===\s*$`)
+
+// sectionName returns the name of the section a line opens, if any.
+func sectionName(line string) (string, bool) {
+	m := sectionPattern.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// sectionComment renders the comment that opens section.
+func sectionComment(section string) string {
+	return "  # === " + section + " ==="
+}
+
+// sectionEnd returns the index in lines just past the last non-blank line of
+// the named section, which runs from its comment to the next section comment
+// or EOF. Section names compare case-insensitively. ok is false when there
+// is no such section.
+func sectionEnd(lines []string, section string) (end int, ok bool) {
+	start := -1
+	for i, line := range lines {
+		if name, ok := sectionName(line); ok && strings.EqualFold(name, section) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return 0, false
+	}
+	end = len(lines)
+	for i := start + 1; i < len(lines); i++ {
+		if _, ok := sectionName(lines[i]); ok {
+			end = i
+			break
+		}
+	}
+	// Blank lines that separate this section from the next one stay after
+	// anything added to it.
+	for end-1 > start && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return end, true
+}
+
+// insertUnderSection returns content with snippet added at the end of the
+// named section (see sectionEnd). A missing section is created at EOF.
+func insertUnderSection(content, section, snippet string) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	entry := strings.Split(strings.TrimRight(snippet, "\n"), "\n")
+
+	end, ok := sectionEnd(lines, section)
+	if !ok {
+		lines = append(lines, "", sectionComment(section))
+		lines = append(lines, strings.Split(strings.TrimLeft(snippet, "\n"), "\n")...)
+		return strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+	}
+
+	out := make([]string, 0, len(lines)+len(entry))
+	out = append(out, lines[:end]...)
+	out = append(out, entry...)
+	out = append(out, lines[end:]...)
+	return strings.Join(out, "\n") + "\n"
+}
+
+// appendUnderSection adds snippet to the end of section in the match file at
+// path, creating the section at EOF when it doesn't exist yet. The file is
+// only rewritten if the result is still a valid match file.
+func appendUnderSection(path, section, snippet string) error {
+	if strings.TrimSpace(section) == "" {
+		return errors.New("section name is empty")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	updated := insertUnderSection(string(b), section, snippet)
+	if err := validateMatchYAML([]byte(updated)); err != nil {
+		return fmt.Errorf("result would be invalid: %w", err)
+	}
+	return os.WriteFile(path, []byte(updated), 0o644)
+}
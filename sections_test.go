@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestAppendUnderSection_ExistingSection(t *testing.T) {
+	orig := matchFileHeader +
+		"\n  # === Email ===\n  - trigger: \":sig\"\n    replace: \"Best\"\n" +
+		"\n  # === Code ===\n  - trigger: \":fn\"\n    replace: \"func\"\n"
+	p := writeMatchFixture(t, orig)
+
+	entry := buildYAMLSnippet([]string{":thx"}, "Thanks", SnippetOptions{})
+	if err := appendUnderSection(p, "email", entry); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := matchFileHeader +
+		"\n  # === Email ===\n  - trigger: \":sig\"\n    replace: \"Best\"\n" +
+		"\n  - trigger: \":thx\"\n    replace: \"Thanks\"\n" +
+		"\n  # === Code ===\n  - trigger: \":fn\"\n    replace: \"func\"\n"
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+	matches, err := parseMatches(b)
+	if err != nil || len(matches) != 3 || matches[1].Triggers[0] != ":thx" {
+		t.Errorf("unexpected matches %+v, err=%v", matches, err)
+	}
+}
+
+func TestAppendUnderSection_LastSection(t *testing.T) {
+	orig := matchFileHeader + "\n  # === Email ===\n  - trigger: \":sig\"\n    replace: \"Best\"\n\n\n"
+	p := writeMatchFixture(t, orig)
+
+	if err := appendUnderSection(p, "Email", buildYAMLSnippet([]string{":thx"}, "Thanks", SnippetOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(p)
+	want := matchFileHeader + "\n  # === Email ===\n  - trigger: \":sig\"\n    replace: \"Best\"\n" +
+		"\n  - trigger: \":thx\"\n    replace: \"Thanks\"\n"
+	if string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestAppendUnderSection_NewSection(t *testing.T) {
+	orig := matchFileHeader + "  - trigger: \":a\"\n    replace: \"A\"\n"
+	p := writeMatchFixture(t, orig)
+
+	if err := appendUnderSection(p, "Email", buildYAMLSnippet([]string{":sig"}, "Best", SnippetOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(p)
+	want := orig + "\n  # === Email ===\n  - trigger: \":sig\"\n    replace: \"Best\"\n"
+	if string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// triggerCompare orders triggers case-insensitively, falling back to a
+// plain comparison so the order is total. Like strings.Compare, it returns
+// -1, 0 or +1.
+func triggerCompare(a, b string) int {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// triggerLess reports whether a sorts before b under triggerCompare.
+func triggerLess(a, b string) bool {
+	return triggerCompare(a, b) < 0
+}
+
+// sortedInsertLine returns the 0-based line in content at which an entry
+// keyed by trigger goes to keep the file sorted: the start of the first
+// entry whose first trigger sorts after it, including the comments directly
+// above that entry. ok is false when no entry does, so the entry belongs at
+// the end.
+func sortedInsertLine(content []byte, trigger string) (at int, ok bool, err error) {
+	matches, err := parseMatches(content)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, m := range matches {
+		if keys := m.AllTriggers(); len(keys) > 0 && triggerLess(trigger, keys[0]) {
+			return entryStart(strings.Split(string(content), "\n"), m.Line), true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// insertSortedByTrigger inserts snippet into the match file at path before
+// the first entry whose first trigger sorts after trigger, keeping a sorted
+// file sorted. Comments directly above that entry stay attached to it. When
+// no entry sorts after trigger (including a file with only the header), the
+// snippet is appended at the end.
+func insertSortedByTrigger(path, trigger, snippet string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	at, ok, err := sortedInsertLine(b, trigger)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return appendEntry(path, "", snippet)
+	}
+
+	lines := strings.Split(string(b), "\n")
+	entry := strings.Split(strings.TrimRight(snippet, "\n"), "\n")
+	out := make([]string, 0, len(lines)+len(entry))
+	out = append(out, lines[:at]...)
+	out = append(out, entry...)
+	out = append(out, lines[at:]...)
+
+	updated := strings.Join(out, "\n")
+	if err := validateMatchYAML([]byte(updated)); err != nil {
+		return fmt.Errorf("result would be invalid: %w", err)
+	}
+	return os.WriteFile(path, []byte(updated), 0o644)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func sortedFixture(triggers ...string) string {
+	var b strings.Builder
+	b.WriteString(matchFileHeader)
+	for _, t := range triggers {
+		b.WriteString(buildYAMLSnippet([]string{t}, strings.ToUpper(t[1:]), SnippetOptions{}))
+	}
+	return b.String()
+}
+
+func triggersOf(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := parseMatchFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range matches {
+		got = append(got, m.Triggers[0])
+	}
+	return got
+}
+
+func TestInsertSortedByTrigger_Positions(t *testing.T) {
+	tests := []struct {
+		name, trigger, want string
+	}{
+		{"first", ":a", ":a :b :d :f"},
+		{"middle", ":c", ":b :c :d :f"},
+		{"last", ":g", ":b :d :f :g"},
+		{"case-insensitive", ":E", ":b :d :E :f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := writeMatchFixture(t, sortedFixture(":b", ":d", ":f"))
+			if err := insertSortedByTrigger(p, tt.trigger, buildYAMLSnippet([]string{tt.trigger}, "x", SnippetOptions{})); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(triggersOf(t, p), " "); got != tt.want {
+				t.Errorf("order = %q, want %q", got, tt.want)
+			}
+			b, _ := os.ReadFile(p)
+			if !strings.HasPrefix(string(b), matchFileHeader) {
+				t.Errorf("header not preserved:\n%s", b)
+			}
+		})
+	}
+}
+
+func TestInsertSortedByTrigger_ExactLayout(t *testing.T) {
+	p := writeMatchFixture(t, sortedFixture(":a", ":c"))
+	if err := insertSortedByTrigger(p, ":b", buildYAMLSnippet([]string{":b"}, "B", SnippetOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(p)
+	if want := sortedFixture(":a", ":b", ":c"); string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+}
+
+func TestInsertSortedByTrigger_FirstEntry(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	if err := insertSortedByTrigger(p, ":m", buildYAMLSnippet([]string{":m"}, "M", SnippetOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(p)
+	if want := sortedFixture(":m"); string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestInsertSortedByTrigger_KeepsCommentsWithEntry(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+
+		buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{})+
+		buildYAMLSnippet([]string{":c"}, "C", SnippetOptions{ID: "id-c"}))
+	if err := insertSortedByTrigger(p, ":b", buildYAMLSnippet([]string{":b"}, "B", SnippetOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := findMatchByID(p, "id-c"); err != nil || m.Triggers[0] != ":c" {
+		t.Errorf("id comment detached from its entry: %+v, err=%v", m, err)
+	}
+	if got := strings.Join(triggersOf(t, p), " "); got != ":a :b :c" {
+		t.Errorf("order = %q", got)
+	}
+}
+
+func TestRun_AppendSortedWithMixedCaseTriggers(t *testing.T) {
+	p := writeMatchFixture(t, sortedFixture(":apple", ":cat"))
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "--append-sorted", "--sort-triggers", "-t", ":Zed", "-t", ":bob", "-r", "x")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if got := strings.Join(triggersOf(t, p), " "); got != ":apple :bob :cat" {
+		t.Errorf("order = %q, want the new entry between :apple and :cat", got)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// httpClient fetches --replace-from-url bodies. Tests swap it for a client
+// that talks to an httptest server.
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// fetchReplace downloads url and returns its body as replacement text, minus
+// a single trailing newline. Non-2xx responses are errors.
+func fetchReplace(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("fetching %s: unexpected status %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// replaceFromCommand runs command (split like a shell would, but without
+// pipes or expansion) and returns its stdout as replacement text, minus a
+// single trailing newline. Its stderr is left out of the replacement; a
+// non-zero exit is an error carrying it.
+func replaceFromCommand(command string) (string, error) {
+	parts, err := splitArgs(command)
+	if err != nil {
+		return "", err
+	}
+	if len(parts) == 0 {
+		return "", errors.New("empty command")
+	}
+	out, err := runCommand(nil, parts[0], parts[1:]...)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", command, err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// includeVarName names the shell var that --include-file emits.
+const includeVarName = "file"
+
+// readIncludeFile returns the contents of the file at path as replacement
+// text, minus a single trailing newline.
+func readIncludeFile(path string) (string, error) {
+	p, err := expandHome(path)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// resolveReplaceArg interprets a --replace value curl-style: @path reads
+// the replacement from the file at path, and a leading @@ stands for a
+// literal @. Anything else is used as given.
+func resolveReplaceArg(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "@@"):
+		return s[1:], nil
+	case strings.HasPrefix(s, "@"):
+		return readIncludeFile(s[1:])
+	}
+	return s, nil
+}
+
+// includeFileVar returns a shell var that cats the file at path when the
+// match expands, so edits to the file show up without re-adding the match.
+// The path is made absolute since espanso doesn't run in our working dir.
+func includeFileVar(path string) (Var, error) {
+	p, err := expandHome(path)
+	if err != nil {
+		return Var{}, err
+	}
+	if p, err = filepath.Abs(p); err != nil {
+		return Var{}, err
+	}
+	return Var{
+		Name:   includeVarName,
+		Type:   "shell",
+		Params: map[string]string{"cmd": "cat " + shellQuote(p)},
+	}, nil
+}
+
+// shellQuote single-quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// loadSnippet returns the text stored under key in the snippet library at
+// libPath, a YAML mapping of keys to text. An unknown key is an error that
+// lists the keys the library does have.
+func loadSnippet(libPath, key string) (string, error) {
+	p, err := expandHome(libPath)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	var lib map[string]string
+	if err := yaml.Unmarshal(b, &lib); err != nil {
+		return "", fmt.Errorf("reading snippet library %s: %w", libPath, err)
+	}
+	text, ok := lib[key]
+	if !ok {
+		keys := sortedKeys(lib)
+		if len(keys) == 0 {
+			return "", fmt.Errorf("snippet %q not found: %s is empty", key, libPath)
+		}
+		return "", fmt.Errorf("snippet %q not found in %s (available: %s)", key, libPath, strings.Join(keys, ", "))
+	}
+	return strings.TrimSuffix(text, "\n"), nil
+}
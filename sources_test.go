@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFetchReplace_Success(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "line one\nline two\n")
+	}))
+	defer srv.Close()
+
+	got, err := fetchReplace(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("fetchReplace error: %v", err)
+	}
+	if got != "line one\nline two" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFetchReplace_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := fetchReplace(srv.Client(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected 404 error, got %v", err)
+	}
+}
+
+func TestReadIncludeFile(t *testing.T) {
+	got, err := readIncludeFile(filepath.Join("testdata", "include.txt"))
+	if err != nil {
+		t.Fatalf("readIncludeFile error: %v", err)
+	}
+	if want := "Dear team,\n\nThanks!"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := readIncludeFile(filepath.Join("testdata", "missing.txt")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestIncludeFileVar(t *testing.T) {
+	v, err := includeFileVar("/tmp/it's here.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buildYAMLSnippet([]string{":inc"}, "{{"+v.Name+"}}", SnippetOptions{Vars: []Var{v}})
+	want := "\n  - trigger: \":inc\"\n    replace: \"{{file}}\"\n" +
+		"    vars:\n      - name: \"file\"\n        type: shell\n        params:\n" +
+		"          cmd: \"cat '/tmp/it'\\\\''s here.txt'\"\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestIncludeFileVar_RelativePathMadeAbsolute(t *testing.T) {
+	v, err := includeFileVar(filepath.Join("testdata", "include.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs, _ := filepath.Abs(filepath.Join("testdata", "include.txt"))
+	if want := "cat '" + abs + "'"; v.Params["cmd"] != want {
+		t.Errorf("cmd = %q, want %q", v.Params["cmd"], want)
+	}
+}
+
+func TestLoadSnippet(t *testing.T) {
+	lib := filepath.Join(t.TempDir(), "library.yml")
+	content := "sig: |\n  Best,\n  Kev\nthanks: Thanks so much!\naddr: 1 Main St\n"
+	if err := os.WriteFile(lib, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadSnippet(lib, "sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Best,\nKev" {
+		t.Errorf("sig = %q", got)
+	}
+	if got, _ := loadSnippet(lib, "thanks"); got != "Thanks so much!" {
+		t.Errorf("thanks = %q", got)
+	}
+
+	_, err = loadSnippet(lib, "bye")
+	if err == nil {
+		t.Fatal("expected an error for a missing key")
+	}
+	if !strings.Contains(err.Error(), `snippet "bye" not found`) || !strings.Contains(err.Error(), "available: addr, sig, thanks") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResolveReplaceArg(t *testing.T) {
+	got, err := resolveReplaceArg("@" + filepath.Join("testdata", "include.txt"))
+	if err != nil || got != "Dear team,\n\nThanks!" {
+		t.Errorf("@file = %q, %v", got, err)
+	}
+	if got, err := resolveReplaceArg("@@handle"); err != nil || got != "@handle" {
+		t.Errorf("@@ = %q, %v", got, err)
+	}
+	if got, err := resolveReplaceArg("plain @ text"); err != nil || got != "plain @ text" {
+		t.Errorf("plain = %q, %v", got, err)
+	}
+	if _, err := resolveReplaceArg("@" + filepath.Join(t.TempDir(), "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected a not-exist error, got %v", err)
+	}
+}
+
+func TestRun_ReplaceFromAtFile(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "-t", ":team", "-r", "@"+filepath.Join("testdata", "include.txt"))
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "replace: |\n      Dear team,\n") {
+		t.Errorf("file contents not used:\n%s", b)
+	}
+}
+
+func TestReplaceFromCommand(t *testing.T) {
+	calls := fakeRunner(t, "2026-10-16\n\n", nil)
+	got, err := replaceFromCommand(`date "+%F"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "2026-10-16\n" {
+		t.Errorf("got %q, want a single trailing newline trimmed", got)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "date +%F" {
+		t.Errorf("calls = %q", *calls)
+	}
+}
+
+func TestReplaceFromCommand_Fails(t *testing.T) {
+	fakeRunner(t, "partial output\n", errors.New("exit status 1: date: invalid option"))
+	_, err := replaceFromCommand("date --bogus")
+	if err == nil {
+		t.Fatal("expected an error for a non-zero exit")
+	}
+	if want := "date --bogus: exit status 1: date: invalid option"; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+	if _, err := replaceFromCommand("  "); err == nil {
+		t.Error("expected an error for an empty command")
+	}
+}
+
+func TestReplaceFromCommand_IgnoresStderr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	got, err := replaceFromCommand(`sh -c "echo hello; echo 'warning: deprecated' >&2"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hello" {
+		t.Errorf("got %q, want stdout only", got)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ChangeSummary records what a modifying command did. Commands that change
+// the match file print it when they finish, unless --quiet is set.
+type ChangeSummary struct {
+	File      string
+	Operation string // "append", "delete" or "backfill-labels"
+	Triggers  []string
+	// Backup is the path of the backup made before the change, if any.
+	Backup string
+}
+
+// printSummary writes s as an aligned block, leaving out the backup line
+// when no backup was made.
+func printSummary(w io.Writer, s ChangeSummary) {
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintf(w, "  file:      %s\n", s.File)
+	fmt.Fprintf(w, "  operation: %s\n", s.Operation)
+	fmt.Fprintf(w, "  triggers:  %s\n", strings.Join(s.Triggers, ", "))
+	if s.Backup != "" {
+		fmt.Fprintf(w, "  backup:    %s\n", s.Backup)
+	}
+}
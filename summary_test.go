@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_SummaryWithBackup(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "-t", ":a", "-t", ":b", "-r", "A", "--backup")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	i := strings.Index(stdout, "Summary:\n")
+	if i < 0 {
+		t.Fatalf("no summary in %q", stdout)
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout[i:], "\n"), "\n")
+	want := []string{
+		"Summary:",
+		"  file:      " + p,
+		"  operation: append",
+		"  triggers:  :a, :b",
+	}
+	if len(lines) != len(want)+1 {
+		t.Fatalf("unexpected summary %q", lines)
+	}
+	for j, w := range want {
+		if lines[j] != w {
+			t.Errorf("line %d = %q, want %q", j, lines[j], w)
+		}
+	}
+	backup := strings.TrimPrefix(lines[len(want)], "  backup:    ")
+	if filepath.Dir(backup) != filepath.Dir(p) || !strings.HasPrefix(filepath.Base(backup), "cliesp.yml.bak-") {
+		t.Errorf("unexpected backup line %q", lines[len(want)])
+	}
+}
+
+func TestRun_QuietSuppressesSummary(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader)
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "-t", ":a", "-r", "A", "-q")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("expected no output, got %q", stdout)
+	}
+}
+
+func TestRun_QuietSuppressesBackfillSummary(t *testing.T) {
+	p := writeMatchFixture(t, matchFileHeader+buildYAMLSnippet([]string{":a"}, "A", SnippetOptions{}))
+	code, stdout, stderr := runCLI(t, "", "--matchFile", p, "--backfill-labels", "--yes", "-q")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("expected no output, got %q", stdout)
+	}
+}
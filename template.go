@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// templateEngines map an engine name to the placeholder syntax it uses for
+// cliesp-time variables. "dollar" can't collide with espanso's own {{var}}
+// tokens.
+var templateEngines = map[string]func(key string) string{
+	"mustache": func(key string) string { return "{{" + key + "}}" },
+	"dollar":   func(key string) string { return "${" + key + "}" },
+}
+
+// defaultTemplateEngine keeps the original {{key}} placeholders.
+const defaultTemplateEngine = "mustache"
+
+// placeholderFunc returns the placeholder syntax of engine; an empty name
+// selects defaultTemplateEngine.
+func placeholderFunc(engine string) (func(string) string, error) {
+	if engine == "" {
+		engine = defaultTemplateEngine
+	}
+	f, ok := templateEngines[engine]
+	if !ok {
+		return nil, fmt.Errorf("unknown template engine %q (available: %s)", engine, strings.Join(sortedKeys(templateEngines), ", "))
+	}
+	return f, nil
+}
+
+// interpolate replaces the placeholders for the keys in vars, written in
+// engine's syntax. Placeholders for other names (such as espanso's own vars)
+// are left alone.
+func interpolate(s string, vars map[string]string, engine string) (string, error) {
+	placeholder, err := placeholderFunc(engine)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range vars {
+		s = strings.ReplaceAll(s, placeholder(k), v)
+	}
+	return s, nil
+}
+
+// renderTemplateFile loads a YAML match fragment from path and replaces the
+// placeholder for each key in vars, written in engine's syntax ({{key}} by
+// default). Placeholders for other names (such as espanso's own vars) are
+// left alone. When a value spans several lines, its continuation lines get
+// the indentation of the placeholder's line so block scalars stay intact. The
+// rendered fragment must parse as one or more match entries.
+func renderTemplateFile(path string, vars map[string]string, engine string) (string, error) {
+	placeholder, err := placeholderFunc(engine)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(b), "\n")
+	for i, line := range lines {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		for k, v := range vars {
+			v = strings.ReplaceAll(v, "\n", "\n"+indent)
+			line = strings.ReplaceAll(line, placeholder(k), v)
+		}
+		lines[i] = line
+	}
+	fragment := strings.Trim(strings.Join(lines, "\n"), "\n")
+	fragment = "\n" + fragment + "\n"
+
+	matches, err := parseMatches([]byte("matches:" + fragment))
+	if err != nil {
+		return "", fmt.Errorf("rendered template is not valid YAML: %w", err)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("rendered template contains no match entries")
+	}
+	for _, m := range matches {
+		if len(m.AllTriggers()) == 0 {
+			return "", fmt.Errorf("rendered template has a match without a trigger (line %d)", m.Line-1)
+		}
+	}
+	return fragment, nil
+}
+
+// templateDirName is the directory under the config dir holding named
+// templates for --template.
+const templateDirName = "templates"
+
+// resolveTemplate turns a --template argument into a file path. A bare name
+// such as "note" refers to templates/note.yml (or .yaml) in dir; anything
+// with a path separator or extension is taken as a path.
+func resolveTemplate(dir, name string) (string, error) {
+	if strings.ContainsRune(name, filepath.Separator) || strings.ContainsRune(name, '/') || filepath.Ext(name) != "" {
+		return expandHome(name)
+	}
+	for _, ext := range []string{".yml", ".yaml"} {
+		p := filepath.Join(dir, templateDirName, name+ext)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no template named %q in %s", name, filepath.Join(dir, templateDirName))
+}
+
+// unresolvedPlaceholders returns the names of placeholders in engine's
+// syntax that are still in fragment, in order of first appearance. Names
+// declared as vars of the rendered matches are espanso's own and don't
+// count.
+func unresolvedPlaceholders(fragment, engine string) ([]string, error) {
+	placeholder, err := placeholderFunc(engine)
+	if err != nil {
+		return nil, err
+	}
+	prefix, suffix, _ := strings.Cut(placeholder("\x00"), "\x00")
+	pattern := regexp.MustCompile(regexp.QuoteMeta(prefix) + `\s*([\w.-]+)\s*` + regexp.QuoteMeta(suffix))
+
+	declared := make(map[string]bool)
+	if matches, err := parseMatches([]byte("matches:" + fragment)); err == nil {
+		for _, m := range matches {
+			for _, v := range m.Vars {
+				declared[v.Name] = true
+			}
+		}
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, sub := range pattern.FindAllStringSubmatch(fragment, -1) {
+		if name := sub[1]; !declared[name] && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
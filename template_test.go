@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTemplate writes a template fixture and returns its path.
+func writeTemplate(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "tpl.yml")
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRenderTemplateFile_Substitutes(t *testing.T) {
+	p := writeTemplate(t, "  - trigger: \"{{trigger}}\"\n    replace: |\n      {{replace}}\n      sent {{date}}\n    word: true\n")
+	got, err := renderTemplateFile(p, map[string]string{"trigger": ":x", "replace": "hi\nthere"}, "")
+	if err != nil {
+		t.Fatalf("renderTemplateFile error: %v", err)
+	}
+	want := "\n  - trigger: \":x\"\n    replace: |\n      hi\n      there\n      sent {{date}}\n    word: true\n"
+	if got != want {
+		t.Errorf("render mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+	matches, err := parseMatches([]byte("matches:" + got))
+	if err != nil || len(matches) != 1 || matches[0].Replace != "hi\nthere\nsent {{date}}\n" {
+		t.Errorf("rendered fragment did not round-trip: %+v err=%v", matches, err)
+	}
+}
+
+func TestRenderTemplateFile_InvalidResult(t *testing.T) {
+	p := writeTemplate(t, "  - trigger: \"{{trigger}}\"\n    replace: {{replace}\n")
+	if _, err := renderTemplateFile(p, map[string]string{"trigger": ":x", "replace": "hi"}, ""); err == nil {
+		t.Fatal("expected validation error for broken YAML")
+	}
+
+	p = writeTemplate(t, "  - replace: \"{{replace}}\"\n")
+	if _, err := renderTemplateFile(p, map[string]string{"replace": "hi"}, ""); err == nil {
+		t.Fatal("expected error for a match without a trigger")
+	}
+}
+
+func TestRenderTemplateFile_DollarEngine(t *testing.T) {
+	p := writeTemplate(t, "  - trigger: \"${trigger}\"\n    replace: \"${replace} on {{date}} for ${team}\"\n")
+	got, err := renderTemplateFile(p, map[string]string{"trigger": ":x", "replace": "hi", "team": "ops"}, "dollar")
+	if err != nil {
+		t.Fatalf("renderTemplateFile error: %v", err)
+	}
+	if want := "\n  - trigger: \":x\"\n    replace: \"hi on {{date}} for ops\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{"name": "Kev", "date": "never"}
+	got, err := interpolate("Hi ${name}, it's {{date}} ${unknown}", vars, "dollar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hi Kev, it's {{date}} ${unknown}"; got != want {
+		t.Errorf("dollar: got %q, want %q", got, want)
+	}
+
+	got, _ = interpolate("Hi {{name}}, {{clipboard}}", vars, "")
+	if want := "Hi Kev, {{clipboard}}"; got != want {
+		t.Errorf("mustache: got %q, want %q", got, want)
+	}
+
+	if _, err := interpolate("x", vars, "jinja"); err == nil || !strings.Contains(err.Error(), "dollar, mustache") {
+		t.Errorf("expected an unknown-engine error listing engines, got %v", err)
+	}
+}
+
+func TestResolveTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tplDir := filepath.Join(dir, templateDirName)
+	if err := os.MkdirAll(tplDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tplDir, "note.yaml"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := resolveTemplate(dir, "note")
+	if err != nil || p != filepath.Join(tplDir, "note.yaml") {
+		t.Errorf("named template = %q, %v", p, err)
+	}
+	if p, err := resolveTemplate(dir, "other/tpl.yml"); err != nil || p != "other/tpl.yml" {
+		t.Errorf("path = %q, %v", p, err)
+	}
+	if _, err := resolveTemplate(dir, "missing"); err == nil || !strings.Contains(err.Error(), `no template named "missing"`) {
+		t.Errorf("expected a not-found error, got %v", err)
+	}
+}
+
+func TestUnresolvedPlaceholders(t *testing.T) {
+	fragment := "\n  - trigger: \":n\"\n    replace: \"{{user}} {{ date }} {{now}} {{user}}\"\n    vars:\n      - name: now\n        type: date\n        params:\n          format: \"%H\"\n"
+	got, err := unresolvedPlaceholders(fragment, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "user,date" {
+		t.Errorf("mustache: got %v, want [user date]", got)
+	}
+
+	got, err = unresolvedPlaceholders("\n  - trigger: \":n\"\n    replace: \"${team} {{time}}\"\n", "dollar")
+	if err != nil || strings.Join(got, ",") != "team" {
+		t.Errorf("dollar: got %v, %v", got, err)
+	}
+}
+
+func TestRun_TemplateWithSet(t *testing.T) {
+	tpl := writeTemplate(t, "  - trigger: \"{{trigger}}\"\n    replace: \"{{replace}} by {{user}} on {{date}}\"\n")
+
+	p := writeMatchFixture(t, matchFileHeader)
+	code, _, stderr := runCLI(t, "", "--matchFile", p, "--template", tpl, "--set", "date=2024-01-01", "--set", "user=kev", "--strict", "-t", ":note", "-r", "Notes")
+	if code != 0 {
+		t.Fatalf("exit=%d stderr=%q", code, stderr)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `replace: "Notes by kev on 2024-01-01"`) {
+		t.Errorf("unexpected file:\n%s", b)
+	}
+
+	p = writeMatchFixture(t, matchFileHeader)
+	code, _, stderr = runCLI(t, "", "--matchFile", p, "--template", tpl, "--set", "user=kev", "--strict", "-t", ":note", "-r", "Notes")
+	if code != exitValidation || !strings.Contains(stderr, "unresolved placeholders: date") {
+		t.Errorf("exit=%d stderr=%q", code, stderr)
+	}
+	if b, _ := os.ReadFile(p); string(b) != matchFileHeader {
+		t.Errorf("nothing should be written under --strict, got %q", b)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// usageLinePattern finds a trigger in an espanso log line. Log formats vary
+// between versions, so it accepts "trigger :x", "trigger: :x", "trigger=:x",
+// "triggered ':x'" and quoted values, case-insensitively.
+var usageLinePattern = regexp.MustCompile(`(?i)\btrigger(?:ed)?\b(?:\s+|\s*[:=]\s*)(?:"([^"]+)"|'([^']+)'|(\S+))`)
+
+// parseUsage counts how often each trigger appears in the espanso log at
+// logPath. Lines that don't mention a trigger are ignored.
+func parseUsage(logPath string) (map[string]int, error) {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := usageLinePattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		t := m[1] + m[2] + m[3]
+		t = strings.TrimRight(t, ",;")
+		if t != "" {
+			counts[t]++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// unusedTriggers returns the triggers defined in matches that never appear in
+// counts, in file order.
+func unusedTriggers(matches []Match, counts map[string]int) []string {
+	var unused []string
+	for _, m := range matches {
+		for _, t := range m.Triggers {
+			if counts[t] == 0 {
+				unused = append(unused, t)
+			}
+		}
+	}
+	return unused
+}
+
+// printUsageReport writes per-trigger counts for the triggers in matches,
+// most used first, followed by the never-used triggers.
+func printUsageReport(w io.Writer, matches []Match, counts map[string]int) {
+	var used []string
+	for _, m := range matches {
+		for _, t := range m.Triggers {
+			if counts[t] > 0 {
+				used = append(used, t)
+			}
+		}
+	}
+	sort.SliceStable(used, func(i, j int) bool { return counts[used[i]] > counts[used[j]] })
+	for _, t := range used {
+		fmt.Fprintf(w, "%6d  %s\n", counts[t], t)
+	}
+	unused := unusedTriggers(matches, counts)
+	if len(unused) == 0 {
+		fmt.Fprintln(w, "All triggers have been used.")
+		return
+	}
+	fmt.Fprintf(w, "\nNever used (%d):\n", len(unused))
+	for _, t := range unused {
+		fmt.Fprintf(w, "  %s\n", t)
+	}
+}
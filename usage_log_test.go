@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseUsage_SampleLog(t *testing.T) {
+	counts, err := parseUsage("testdata/espanso.log")
+	if err != nil {
+		t.Fatalf("parseUsage error: %v", err)
+	}
+	want := map[string]int{":sig": 3, ":addr": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts mismatch got=%v want=%v", counts, want)
+	}
+}
+
+func TestParseUsage_MissingFile(t *testing.T) {
+	if _, err := parseUsage("testdata/does-not-exist.log"); err == nil {
+		t.Fatal("expected error for missing log file")
+	}
+}
+
+func TestUnusedTriggers_CrossReference(t *testing.T) {
+	content := "matches:\n  - trigger: \":sig\"\n    replace: \"s\"\n  - triggers: [\":addr\", \":home\"]\n    replace: \"a\"\n  - trigger: \":old\"\n    replace: \"o\"\n"
+	matches, err := parseMatches([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, err := parseUsage("testdata/espanso.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := unusedTriggers(matches, counts)
+	want := []string{":home", ":old"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unused mismatch got=%v want=%v", got, want)
+	}
+
+	var buf bytes.Buffer
+	printUsageReport(&buf, matches, counts)
+	out := buf.String()
+	if !strings.Contains(out, "3  :sig") || !strings.Contains(out, "Never used (2):") {
+		t.Errorf("unexpected report:\n%s", out)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validateTriggers checks each trigger before anything is written. When
+// maxLen is greater than zero, triggers longer than maxLen characters are
+// rejected; the error names the offending trigger and its length.
+func validateTriggers(triggers []string, maxLen int) error {
+	for _, t := range triggers {
+		if n := utf8.RuneCountInString(t); maxLen > 0 && n > maxLen {
+			return fmt.Errorf("trigger %q is %d characters long (max %d)", t, n, maxLen)
+		}
+	}
+	return nil
+}
+
+// validateTrigger rejects triggers espanso would fail to load: empty or
+// whitespace-only ones and those with newlines or other control characters.
+func validateTrigger(t string) error {
+	if strings.TrimSpace(t) == "" {
+		return fmt.Errorf("trigger %q is empty or only whitespace", t)
+	}
+	if strings.ContainsAny(t, "\r\n") {
+		return fmt.Errorf("trigger %q contains a newline", t)
+	}
+	for _, r := range t {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("trigger %q contains control character %U", t, r)
+		}
+	}
+	return nil
+}
+
+// isBlankReplace reports whether a replacement is empty or whitespace-only.
+func isBlankReplace(s string) bool {
+	return strings.TrimSpace(s) == ""
+}
+
+// checkEmptyReplace guards against accidentally empty replacements. A blank
+// replacement is an error unless allowEmpty is set, in which case it is
+// normalized to "" so the emitted entry is `replace: ""`.
+func checkEmptyReplace(s string, allowEmpty bool) (string, error) {
+	if !isBlankReplace(s) {
+		return s, nil
+	}
+	if !allowEmpty {
+		return "", fmt.Errorf("replacement is empty, exiting (use --allow-empty to append it anyway)")
+	}
+	return "", nil
+}
+
+// checkUTF8 returns an error naming the byte offset of the first invalid
+// UTF-8 sequence in s, unless allowInvalid is set. Text pasted from a latin-1
+// source would otherwise end up as broken bytes in the match file.
+func checkUTF8(s string, allowInvalid bool) error {
+	if allowInvalid || utf8.ValidString(s) {
+		return nil
+	}
+	for i, r := range s {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				return fmt.Errorf("replacement is not valid UTF-8 (invalid byte 0x%02x at offset %d); convert it first or use --allow-invalid-utf8", s[i], i)
+			}
+		}
+	}
+	return fmt.Errorf("replacement is not valid UTF-8 (use --allow-invalid-utf8 to append it anyway)")
+}
+
+// validateMatchYAML checks that content parses as YAML and has the `matches:`
+// root key espanso expects.
+func validateMatchYAML(content []byte) error {
+	var doc map[string]any
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return err
+	}
+	if _, ok := doc["matches"]; !ok {
+		return fmt.Errorf("missing root key 'matches'")
+	}
+	return nil
+}
+
+// validateMatchFile checks that the file at path is valid YAML with a
+// `matches:` list whose entries parse.
+func validateMatchFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := validateMatchYAML(b); err != nil {
+		return err
+	}
+	_, err = parseMatches(b)
+	return err
+}
+
+// FileResult is the outcome of validating one match file. Err is nil when the
+// file passed.
+type FileResult struct {
+	Path string
+	Err  error
+}
+
+// validateDir validates every *.yml/*.yaml file under dir, recursively, in
+// lexical order.
+func validateDir(dir string) ([]FileResult, error) {
+	var results []FileResult
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isMatchFileName(d.Name()) {
+			return nil
+		}
+		results = append(results, FileResult{Path: p, Err: validateMatchFile(p)})
+		return nil
+	})
+	return results, err
+}
+
+// printFileResults writes a pass/fail line per file and reports whether all
+// of them passed.
+func printFileResults(w io.Writer, results []FileResult) bool {
+	if len(results) == 0 {
+		fmt.Fprintln(w, "no match files found")
+		return true
+	}
+	ok := true
+	for _, r := range results {
+		if r.Err != nil {
+			ok = false
+			fmt.Fprintf(w, "FAIL  %s: %v\n", r.Path, r.Err)
+			continue
+		}
+		fmt.Fprintf(w, "ok    %s\n", r.Path)
+	}
+	return ok
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTriggers_MaxLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger string
+		wantErr bool
+	}{
+		{name: "below limit", trigger: ":abcd", wantErr: false},
+		{name: "at limit", trigger: ":abcde", wantErr: false},
+		{name: "above limit", trigger: ":abcdef", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTriggers([]string{tt.trigger}, 6)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateTriggers(%q) err=%v wantErr=%v", tt.trigger, err, tt.wantErr)
+			}
+			if err != nil && (!strings.Contains(err.Error(), tt.trigger) || !strings.Contains(err.Error(), "7")) {
+				t.Errorf("error should name trigger and length, got %q", err)
+			}
+		})
+	}
+}
+
+func TestValidateTriggers_ZeroDisablesLimit(t *testing.T) {
+	long := ":" + strings.Repeat("x", 200)
+	if err := validateTriggers([]string{long}, 0); err != nil {
+		t.Fatalf("expected no error with limit disabled, got %v", err)
+	}
+}
+
+func TestCheckEmptyReplace(t *testing.T) {
+	if _, err := checkEmptyReplace("  \n", false); err == nil {
+		t.Fatal("expected error for blank replace without --allow-empty")
+	}
+	got, err := checkEmptyReplace("hello", false)
+	if err != nil || got != "hello" {
+		t.Fatalf("non-empty replace should pass through, got %q err=%v", got, err)
+	}
+}
+
+func TestCheckEmptyReplace_AllowEmpty(t *testing.T) {
+	r, err := checkEmptyReplace(" \n ", true)
+	if err != nil {
+		t.Fatalf("unexpected error with --allow-empty: %v", err)
+	}
+	got := buildYAMLSnippet([]string{":nothing"}, r, SnippetOptions{})
+	want := "\n  - trigger: \":nothing\"\n    replace: \"\"\n"
+	if got != want {
+		t.Errorf("empty replace YAML mismatch\nGot:\n%q\nWant:\n%q", got, want)
+	}
+}
+
+func TestValidateTrigger(t *testing.T) {
+	for _, ok := range []string{":sig", ":foo ", "  :x", "日本"} {
+		if err := validateTrigger(ok); err != nil {
+			t.Errorf("validateTrigger(%q) = %v, want nil", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "   ", "\t", ":a\nb", ":a\r", ":a\x00", ":a\x1b[0m"} {
+		if err := validateTrigger(bad); err == nil {
+			t.Errorf("validateTrigger(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCheckUTF8(t *testing.T) {
+	for _, ok := range []string{"", "plain", "héllo wörld", "日本語", "emoji 🎉"} {
+		if err := checkUTF8(ok, false); err != nil {
+			t.Errorf("checkUTF8(%q) = %v, want nil", ok, err)
+		}
+	}
+	for _, bad := range []string{"caf\xe9", "\xff\xfe", "ok\xc3", "\xed\xa0\x80"} {
+		err := checkUTF8(bad, false)
+		if err == nil || !strings.Contains(err.Error(), "not valid UTF-8") {
+			t.Errorf("checkUTF8(%q) = %v, want an invalid UTF-8 error", bad, err)
+		}
+		if err := checkUTF8(bad, true); err != nil {
+			t.Errorf("checkUTF8(%q, allow) = %v, want nil", bad, err)
+		}
+	}
+	if err := checkUTF8("caf\xe9", false); !strings.Contains(err.Error(), "0xe9 at offset 3") {
+		t.Errorf("error should locate the bad byte, got %v", err)
+	}
+}
+
+func TestValidateDir_MixedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"good.yml":          matchFileHeader + "  - trigger: \":a\"\n    replace: \"A\"\n",
+		"also-good.yaml":    "matches: []\n",
+		"sub/nested.yml":    "matches:\n  - trigger: [broken\n",
+		"no-matches.yml":    "global_vars: []\n",
+		"notes.txt":         "not yaml: [",
+		"sub/bad-entry.yml": "matches:\n  - trigger: [\":a\"]\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := validateDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, r := range results {
+		rel, _ := filepath.Rel(dir, r.Path)
+		got[filepath.ToSlash(rel)] = r.Err == nil
+	}
+	want := map[string]bool{
+		"good.yml":          true,
+		"also-good.yaml":    true,
+		"sub/nested.yml":    false,
+		"no-matches.yml":    false,
+		"sub/bad-entry.yml": false,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got results for %v, want %v", got, want)
+	}
+	for name, pass := range want {
+		if got[name] != pass {
+			t.Errorf("%s: pass=%v, want %v", name, got[name], pass)
+		}
+	}
+
+	var buf bytes.Buffer
+	if printFileResults(&buf, results) {
+		t.Error("printFileResults should report failure")
+	}
+	if !strings.Contains(buf.String(), "FAIL  "+filepath.Join(dir, "no-matches.yml")) {
+		t.Errorf("unexpected report:\n%s", buf.String())
+	}
+}